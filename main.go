@@ -2,101 +2,512 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
-	"sync"
+	"reflect"
+	"strings"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/api"
 	"github.com/exo7-ca/k8s-http-monitor/pkg/config"
 	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/health"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/leader"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
 	"github.com/exo7-ca/k8s-http-monitor/pkg/metrics"
 	"github.com/exo7-ca/k8s-http-monitor/pkg/monitoring"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/server"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/sharding"
 )
 
-func startHealthServer(ctx context.Context, wg *sync.WaitGroup) {
-	defer wg.Done()
+// Number of missed monitoring intervals after which the check loop is considered stalled
+const livenessStallIntervals = 3
+
+// flags holds the command-line flags
+type flags struct {
+	configFile    string
+	kubeconfig    string
+	context       string
+	masterURL     string
+	logLevel      string
+	listenAddress string
+}
 
-	// Create a simple health check handler
-	http.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
-		// For liveness, just return 200 if the server is running
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+// parseFlags parses the command-line flags
+func parseFlags(args []string) *flags {
+	f := &flags{}
+	fs := flag.NewFlagSet("k8s-http-monitor", flag.ExitOnError)
+	fs.StringVar(&f.configFile, "config", "", "Path to the configuration file, defaults to $CONFIG_FILE or the first config.yaml in the search paths")
+	fs.StringVar(&f.kubeconfig, "kubeconfig", "", "Path to a kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config")
+	fs.StringVar(&f.context, "context", "", "Kubeconfig context to use, defaults to the current context")
+	fs.StringVar(&f.masterURL, "master", "", "Address of the Kubernetes API server, overrides the kubeconfig")
+	fs.StringVar(&f.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	fs.StringVar(&f.listenAddress, "listen-address", "", "Listen address of the health and API server, overrides the configuration")
+	fs.Parse(args)
+	return f
+}
+
+// validate loads the configuration, reports every problem found and returns the exit code
+func validate(args []string) int {
+	f := parseFlags(args)
+	cfg, err := config.LoadConfigFrom(f.configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "  - %s\n", line)
+		}
+		return 1
+	}
+
+	source := cfg.File
+	if source == "" {
+		source = "defaults and environment variables"
+	}
+	fmt.Printf("Configuration from %s is valid\n", source)
+	return 0
+}
+
+// newReloader creates a config reloader that applies the settings which can change
+// at runtime and keeps the current configuration when the new one is invalid
+func newReloader(f *flags, cfg *config.Config, monitor *monitoring.Monitor, discoveryClients []*discovery.Client, metricsProvider *metrics.Provider) *config.Reloader {
+	current := cfg
+	return config.NewReloader(f.configFile, config.DefaultReloadInterval, func(next *config.Config, err error) {
+		result := "success"
+		if err != nil {
+			result = "failure"
+			logging.Errorf("Rejected configuration reload, keeping the current configuration: %v", err)
+		} else {
+			if f.listenAddress != "" {
+				next.ServerAddress = f.listenAddress
+			}
+			applyConfig(current, next, monitor, discoveryClients)
+			current = next
+		}
+		metricsProvider.GetReloadCounter().Add(context.Background(), 1,
+			metric.WithAttributes(attribute.String("result", result)))
 	})
+}
+
+// applyConfig applies the monitoring interval, success status codes and namespace
+// filter of a reloaded configuration; other settings are only read at startup
+func applyConfig(current, next *config.Config, monitor *monitoring.Monitor, discoveryClients []*discovery.Client) {
+	monitor.SetCheckInterval(next.MonitoringInterval)
+	monitor.SetSuccessStatusCodes(next.SuccessStatusCodes)
+	for _, client := range discoveryClients {
+		if err := setNamespaceFilter(client, next); err != nil {
+			logging.Errorf("Error applying the namespace filter of cluster %q: %v", client.Cluster(), err)
+		}
+	}
+	// Run a cycle right away so endpoints of newly excluded namespaces are dropped
+	monitor.Rebalance()
+	logging.Infof("Configuration reloaded: monitoring interval=%v, success status codes=%v, namespace mode=%s, namespaces=%v, allowed=%v, denied=%v",
+		next.MonitoringInterval, next.SuccessStatusCodes, next.NamespaceMode, next.Namespaces, next.AllowNamespaces, next.DenyNamespaces)
 
-	http.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
-		// For readiness, check if all components are initialized
-		// You might want to check if the Kubernetes client and metrics are working
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Ready"))
+	reloadable := func(c config.Config) config.Config {
+		c.MonitoringInterval = 0
+		c.SuccessStatusCodes = nil
+		c.NamespaceMode = ""
+		c.Namespaces = nil
+		c.AllowNamespaces = nil
+		c.DenyNamespaces = nil
+		return c
+	}
+	if !reflect.DeepEqual(reloadable(*current), reloadable(*next)) {
+		logging.Warnf("Configuration changes other than the monitoring interval, success status codes and namespaces take effect after a restart")
+	}
+}
+
+// setNamespaceFilter applies the namespace mode, list and patterns to a discovery client
+func setNamespaceFilter(client *discovery.Client, cfg *config.Config) error {
+	return client.SetNamespaceSelection(discovery.NamespaceSelection{
+		Mode:       cfg.NamespaceMode,
+		Namespaces: cfg.Namespaces,
+		Allow:      cfg.AllowNamespaces,
+		Deny:       cfg.DenyNamespaces,
 	})
+}
+
+// newServer creates the HTTP server for the health probes and the API on a dedicated mux
+func newServer(cfg *config.Config, checker *health.Checker, apiHandler *api.Handler) *server.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health/live", checker.LiveHandler())
+	mux.HandleFunc("/health/ready", checker.ReadyHandler())
+	apiHandler.Register(mux)
 
-	server := &http.Server{
-		Addr: ":8080",
+	options := []server.Option{
+		server.WithReadTimeout(cfg.ServerReadTimeout),
+		server.WithWriteTimeout(cfg.ServerWriteTimeout),
+	}
+	if cfg.TLSCertFile != "" {
+		options = append(options, server.WithTLS(cfg.TLSCertFile, cfg.TLSKeyFile))
 	}
 
-	// Start the server in a goroutine
-	go func() {
-		log.Println("Starting health check server on :8080")
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("Health check server failed: %v", err)
+	return server.New(cfg.ServerAddress, mux, options...)
+}
+
+// registerHealthChecks wires the application components into the liveness and readiness probes
+func registerHealthChecks(checker *health.Checker, discoveryClients []*discovery.Client, metricsProvider *metrics.Provider, monitor *monitoring.Monitor) {
+	// Ready once Ingresses have been listed from the Kubernetes API of every cluster
+	checker.AddReadinessCheck("discovery", func() (string, error) {
+		var pending []string
+		for _, client := range discoveryClients {
+			if !client.HasSynced() {
+				pending = append(pending, fmt.Sprintf("%q", client.Cluster()))
+			}
 		}
-	}()
+		if len(pending) > 0 {
+			return "", fmt.Errorf("ingresses have not been listed yet in clusters %s", strings.Join(pending, ", "))
+		}
+		return fmt.Sprintf("ingress cache synced in %d clusters", len(discoveryClients)), nil
+	})
+
+	// Ready while the metrics exporter runs and its last export succeeded
+	checker.AddReadinessCheck("metrics", func() (string, error) {
+		lastExport, err := metricsProvider.Ready()
+		if err != nil {
+			return "", err
+		}
+		if lastExport.IsZero() {
+			return "exporter initialized, no export yet", nil
+		}
+		return fmt.Sprintf("last export at %s", lastExport.Format(time.RFC3339)), nil
+	})
+
+	// Alive as long as the check loop keeps making progress
+	checker.AddLivenessCheck("scheduler", func() (string, error) {
+		lastProgress := monitor.LastProgress()
+		if lastProgress.IsZero() {
+			return "not started", nil
+		}
+		since := time.Since(lastProgress)
+		if since > livenessStallIntervals*monitor.CheckInterval() {
+			return "", fmt.Errorf("no scheduler progress for %s", since.Round(time.Second))
+		}
+		return fmt.Sprintf("last iteration %s ago", since.Round(time.Second)), nil
+	})
+}
+
+// podIdentity returns a unique identity for this replica, preferring the pod name
+func podIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to determine replica identity: %v", err)
+	}
+	return hostname
+}
+
+// newDiscoveryClients creates a discovery client for each configured cluster, or
+// for the local cluster selected by the command-line flags when none is configured
+func newDiscoveryClients(cfg *config.Config, f *flags) ([]*discovery.Client, error) {
+	selectors := discovery.Selectors{
+		IngressLabels:   cfg.Selectors.IngressLabels,
+		IngressFields:   cfg.Selectors.IngressFields,
+		NamespaceLabels: cfg.Selectors.NamespaceLabels,
+	}
+	classes := discovery.IngressClasses{Include: cfg.IngressClasses}
+	if len(cfg.IngressClassConfigs) > 0 {
+		classes.Settings = make(map[string]discovery.IngressClassSettings, len(cfg.IngressClassConfigs))
+		for name, settings := range cfg.IngressClassConfigs {
+			classes.Settings[name] = discovery.IngressClassSettings{
+				Scheme:       settings.Scheme,
+				ProbeAddress: settings.ProbeAddress,
+			}
+		}
+	}
+
+	// The mode was validated with the configuration
+	probeMode, _ := discovery.ParseProbeMode(cfg.ProbeMode)
+	probe := discovery.ProbeOptions{Mode: probeMode, ControllerAddress: cfg.ControllerAddress}
+	resolution, _ := discovery.ParseBackendResolution(cfg.BackendResolution)
+	backends := discovery.BackendOptions{Enabled: cfg.ProbeBackends, Resolution: resolution, Pods: cfg.ProbePods}
+	services := discovery.ServiceOptions{Enabled: cfg.ServiceDiscovery, ClusterDomain: cfg.ClusterDomain}
+	sources := discovery.ResourceSources{
+		Routes:          cfg.Sources.Routes,
+		VirtualServices: cfg.Sources.VirtualServices,
+		IngressRoutes:   cfg.Sources.IngressRoutes,
+	}
+
+	var clusters []discovery.ClusterOptions
+	for _, cluster := range cfg.Clusters {
+		clusters = append(clusters, discovery.ClusterOptions{
+			Name:       cluster.Name,
+			Kubeconfig: cluster.Kubeconfig,
+			Context:    cluster.Context,
+			InCluster:  cluster.InCluster,
+			Selectors:  selectors,
+			Classes:    classes,
+			Probe:      probe,
+			Backends:   backends,
+			Services:   services,
+			Sources:    sources,
+		})
+	}
+	if len(clusters) == 0 {
+		clusters = []discovery.ClusterOptions{{
+			Name:       cfg.Location.Cluster,
+			Kubeconfig: f.kubeconfig,
+			Context:    f.context,
+			MasterURL:  f.masterURL,
+			Selectors:  selectors,
+			Classes:    classes,
+			Probe:      probe,
+			Backends:   backends,
+			Services:   services,
+			Sources:    sources,
+		}}
+	} else if f.kubeconfig != "" || f.context != "" || f.masterURL != "" {
+		logging.Warnf("Ignoring --kubeconfig, --context and --master because clusters are configured")
+	}
+
+	var clients []*discovery.Client
+	for _, cluster := range clusters {
+		client, err := discovery.NewClientForCluster(cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		// Set namespace filtering
+		if err := setNamespaceFilter(client, cfg); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
 
-	// Wait for context cancellation to shutdown
-	<-ctx.Done()
-	log.Println("Shutting down health check server")
-	server.Shutdown(context.Background())
+	return clients, nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	// Create context that listens for the interrupt signal from the OS
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	f := parseFlags(os.Args[1:])
+	level, err := logging.ParseLevel(f.logLevel)
+	if err != nil {
+		log.Fatalf("Invalid --log-level: %v", err)
+	}
+	logging.SetLevel(level)
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfigFrom(f.configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
-	log.Printf("Configuration loaded: monitoring interval=%v, metrics interval=%v, otel collector URL=%s",
-		cfg.MonitoringInterval, cfg.MetricsInterval, cfg.OtelCollectorURL)
+	if f.listenAddress != "" {
+		cfg.ServerAddress = f.listenAddress
+	}
+	if cfg.File != "" {
+		logging.Infof("Loaded configuration file %s", cfg.File)
+	} else {
+		logging.Warnf("No configuration file found in %s, using defaults and environment variables", strings.Join(config.ConfigSearchPaths, ", "))
+	}
+	logging.Infof("Configuration loaded: monitoring interval=%v, metrics interval=%v, otel collector URL=%s, location=%s/%s/%s",
+		cfg.MonitoringInterval, cfg.MetricsInterval, cfg.OtelCollectorURL,
+		cfg.Location.Region, cfg.Location.Zone, cfg.Location.Cluster)
 
-	// Initialize the metrics provider
-	metricsProvider, err := metrics.NewProvider(ctx, cfg.OtelCollectorURL, cfg.MetricsInterval)
+	// Initialize the metrics provider, identifying the shard in sharding mode
+	metricsOptions := []metrics.Option{
+		metrics.WithLocation(cfg.Location.Region, cfg.Location.Zone, cfg.Location.Cluster),
+	}
+	if cfg.Sharding.Enabled {
+		metricsOptions = append(metricsOptions, metrics.WithResourceAttributes(attribute.String("shard", podIdentity())))
+	}
+	metricsProvider, err := metrics.NewProvider(ctx, cfg.OtelCollectorURL, cfg.MetricsInterval, metricsOptions...)
 	if err != nil {
 		log.Fatalf("Failed to initialize metrics provider: %v", err)
 	}
-	defer metricsProvider.Shutdown(ctx)
 
-	// Create a Kubernetes client
-	discoveryClient, err := discovery.NewClient()
+	// Create a Kubernetes client per cluster; the first cluster also hosts the
+	// Lease and peer EndpointSlices used to coordinate replicas
+	discoveryClients, err := newDiscoveryClients(cfg, f)
 	if err != nil {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
+	localClientset := discoveryClients[0].Clientset()
 
-	// Set namespace filtering
-	discoveryClient.SetNamespaceFilter(cfg.NamespaceMode, cfg.Namespaces)
-
-	// Create the monitor
-	monitor := monitoring.NewMonitor(
-		discoveryClient,
-		metricsProvider,
+	monitorOptions := []monitoring.Option{
 		monitoring.WithCheckInterval(cfg.MonitoringInterval),
-		monitoring.WithTimeout(10*time.Second),
+		monitoring.WithTimeout(cfg.CheckTimeout),
 		monitoring.WithSuccessStatusCodes(cfg.SuccessStatusCodes),
+		monitoring.WithHTTPSRedirectCheck(cfg.VerifyHTTPSRedirect),
+		monitoring.WithLocation(monitoring.Location{
+			Region:  cfg.Location.Region,
+			Zone:    cfg.Location.Zone,
+			Cluster: cfg.Location.Cluster,
+		}),
+	}
+
+	// Outside the home cluster, only probe the public hosts matching the patterns
+	if !cfg.Location.Home {
+		logging.Infof("Probing from outside the home cluster, only hosts matching %v are checked", cfg.Location.RemoteHostPatterns)
+		monitorOptions = append(monitorOptions, monitoring.WithRemoteHostPatterns(cfg.Location.RemoteHostPatterns))
+	}
+
+	// In sharding mode, each replica only checks the endpoints it owns on the
+	// consistent hash ring built from its peers
+	var monitor *monitoring.Monitor
+	var coordinator *sharding.Coordinator
+	if cfg.Sharding.Enabled {
+		coordinator = sharding.NewCoordinator(
+			localClientset,
+			cfg.Sharding.PeerNamespace,
+			cfg.Sharding.PeerService,
+			podIdentity(),
+			func() { monitor.Rebalance() },
+		)
+		monitorOptions = append(monitorOptions, monitoring.WithShardFilter(coordinator.Owns))
+	}
+
+	// Create the monitor
+	monitor = monitoring.NewMonitor(discoveryClients, metricsProvider, monitorOptions...)
+
+	// Register the health checks
+	checker := health.NewChecker()
+	registerHealthChecks(checker, discoveryClients, metricsProvider, monitor)
+	if coordinator != nil {
+		checker.AddReadinessCheck("sharding", func() (string, error) {
+			if !coordinator.HasSynced() {
+				return "", errors.New("peers have not been listed yet")
+			}
+			return fmt.Sprintf("shard %s of %d members", coordinator.Identity(), len(coordinator.Members())), nil
+		})
+	}
+
+	// With leader election, only the leader checks endpoints while standby
+	// replicas keep their Ingress cache warm
+	var elector *leader.Elector
+	if cfg.LeaderElection.Enabled {
+		monitor.SetActive(false)
+
+		elector, err = leader.NewElector(
+			localClientset,
+			leader.Config{
+				LeaseName:      cfg.LeaderElection.LeaseName,
+				LeaseNamespace: cfg.LeaderElection.LeaseNamespace,
+				Identity:       podIdentity(),
+				LeaseDuration:  cfg.LeaderElection.LeaseDuration,
+				RenewDeadline:  cfg.LeaderElection.RenewDeadline,
+				RetryPeriod:    cfg.LeaderElection.RetryPeriod,
+			},
+			metricsProvider,
+			leader.Callbacks{
+				OnStartedLeading: func(context.Context) { monitor.SetActive(true) },
+				OnStoppedLeading: func() { monitor.SetActive(false) },
+			},
+		)
+		if err != nil {
+			log.Fatalf("Failed to set up leader election: %v", err)
+		}
+
+		// Standby replicas report no endpoints, so they are kept out of the
+		// Service and API requests only reach the leader
+		checker.AddReadinessCheck("leader-election", func() (string, error) {
+			if !elector.IsLeader() {
+				return "", errors.New(elector.Status())
+			}
+			return elector.Status(), nil
+		})
+	}
+
+	// Create the API handler
+	apiHandler := api.NewHandler(
+		monitor,
+		api.WithToken(cfg.APIToken),
+		api.WithCheckRateLimit(cfg.APICheckRateLimit),
 	)
 
-	go startHealthServer(ctx, &wg)
+	// Start the health and API server
+	srv := newServer(cfg, checker, apiHandler)
+	serverErrors, err := srv.Start()
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+
+	// Start watching Ingresses and wait for the initial listing
+	// An unreachable cluster must not hold up monitoring the others, so the wait
+	// is bounded; clusters that sync later are picked up on the next interval
+	for _, client := range discoveryClients {
+		client.Start(ctx)
+	}
+	syncCtx, cancelSync := context.WithTimeout(ctx, cfg.MonitoringInterval)
+	for _, client := range discoveryClients {
+		if !client.WaitForSync(syncCtx) {
+			logging.Warnf("Ingress cache of cluster %q has not synced yet", client.Cluster())
+		}
+	}
+	cancelSync()
 
-	// Start the monitoring
+	// Start peer discovery, leader election and the monitoring
+	// Until the peers are known, the replica owns no endpoint, so the first cycle
+	// waits for them rather than checking nothing
+	if coordinator != nil {
+		coordinator.Start(ctx)
+		syncCtx, cancelSync := context.WithTimeout(ctx, cfg.MonitoringInterval)
+		if !coordinator.WaitForSync(syncCtx) {
+			logging.Warnf("Shard peers have not synced yet, endpoints are checked once they have")
+		}
+		cancelSync()
+	}
+	if elector != nil {
+		go elector.Run(ctx)
+	}
 	monitor.Start(ctx)
 
-	// Wait for termination signal
-	wg.Wait()
-	log.Println("Application shutdown complete")
+	// Reload the configuration when its file changes or on SIGHUP
+	reloader := newReloader(f, cfg, monitor, discoveryClients, metricsProvider)
+	go reloader.Run(ctx)
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangups:
+				logging.Infof("Received SIGHUP, reloading configuration")
+				reloader.Reload()
+			}
+		}
+	}()
+
+	// Wait for termination signal or a server failure
+	select {
+	case <-ctx.Done():
+		logging.Infof("Received termination signal")
+	case err := <-serverErrors:
+		logging.Errorf("Server failed: %v", err)
+		stop()
+	}
+
+	// Drain in-flight work and flush metrics within the grace period
+	logging.Infof("Shutting down, waiting up to %v", cfg.ShutdownGracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logging.Errorf("Error shutting down server: %v", err)
+	}
+	if err := monitor.Shutdown(shutdownCtx); err != nil {
+		logging.Errorf("Error draining checks: %v", err)
+	}
+	metricsProvider.Shutdown(shutdownCtx)
+
+	logging.Infof("Application shutdown complete")
 }
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/monitoring"
+)
+
+// Handler serves the monitor's HTTP API
+type Handler struct {
+	monitor        *monitoring.Monitor
+	token          string
+	checkRateLimit time.Duration
+	mu             sync.Mutex // Protects lastCheck
+	lastCheck      map[string]time.Time
+}
+
+// Option is a functional option for configuring the API handler
+type Option func(*Handler)
+
+// WithToken requires API requests to present the given bearer token
+func WithToken(token string) Option {
+	return func(h *Handler) {
+		h.token = token
+	}
+}
+
+// WithCheckRateLimit sets the minimum interval between on-demand checks of the same endpoint
+func WithCheckRateLimit(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.checkRateLimit = interval
+	}
+}
+
+// NewHandler creates a new API handler
+func NewHandler(monitor *monitoring.Monitor, options ...Option) *Handler {
+	h := &Handler{
+		monitor:        monitor,
+		checkRateLimit: 10 * time.Second,
+		lastCheck:      make(map[string]time.Time),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+// Register adds the API routes to the given mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/endpoints", h.authorize(h.listEndpoints))
+	mux.HandleFunc("GET /api/v1/events", h.authorize(h.streamEvents))
+	mux.HandleFunc("GET /api/v1/skipped", h.authorize(h.listSkipped))
+	mux.HandleFunc("POST /api/v1/endpoints/{id}/check", h.authorize(h.checkEndpoint))
+	mux.HandleFunc("POST /api/v1/namespaces/{namespace}/ingresses/{ingress}/check", h.authorize(h.checkIngress))
+}
+
+// authorize wraps a handler with the optional bearer token guard
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) listEndpoints(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.monitor.Endpoints())
+}
+
+func (h *Handler) listSkipped(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.monitor.SkippedRules())
+}
+
+func (h *Handler) checkEndpoint(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if wait := h.reserve(id); wait > 0 {
+		writeRateLimited(w, wait)
+		return
+	}
+
+	result, err := h.monitor.CheckEndpointByID(r.Context(), id)
+	if err != nil {
+		h.release(id)
+		if errors.Is(err, monitoring.ErrEndpointNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("endpoint %q not found", id))
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) checkIngress(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	ingress := r.PathValue("ingress")
+	cluster := r.URL.Query().Get("cluster")
+
+	// Rate limit every endpoint that belongs to the ingress
+	var ids []string
+	for _, endpoint := range h.monitor.Endpoints() {
+		if (cluster == "" || endpoint.Cluster == cluster) && endpoint.Namespace == namespace && endpoint.Ingress == ingress {
+			ids = append(ids, endpoint.ID)
+		}
+	}
+	if len(ids) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("ingress %s/%s has no monitored endpoints", namespace, ingress))
+		return
+	}
+	if wait := h.reserve(ids...); wait > 0 {
+		writeRateLimited(w, wait)
+		return
+	}
+
+	results, err := h.monitor.CheckIngress(r.Context(), cluster, namespace, ingress)
+	if err != nil {
+		h.release(ids...)
+		if errors.Is(err, monitoring.ErrEndpointNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("ingress %s/%s has no monitored endpoints", namespace, ingress))
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// reserve records an on-demand check for the given endpoint IDs. If any of them
+// was checked within the rate limit window, nothing is recorded and the time to
+// wait before retrying is returned. Checks older than the window are forgotten,
+// so endpoints that are no longer checked, or no longer exist, are not kept.
+func (h *Handler) reserve(ids ...string) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for id, last := range h.lastCheck {
+		if now.Sub(last) >= h.checkRateLimit {
+			delete(h.lastCheck, id)
+		}
+	}
+
+	var wait time.Duration
+	for _, id := range ids {
+		if last, ok := h.lastCheck[id]; ok {
+			if remaining := h.checkRateLimit - now.Sub(last); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for _, id := range ids {
+		h.lastCheck[id] = now
+	}
+	return 0
+}
+
+// release forgets reservations for checks that never ran
+func (h *Handler) release(ids ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range ids {
+		delete(h.lastCheck, id)
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(wait.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, fmt.Sprintf("endpoint was checked recently, retry in %ds", seconds))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Errorf("Error encoding API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
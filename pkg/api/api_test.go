@@ -0,0 +1,339 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/metrics"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/monitoring"
+)
+
+func newTestMux(options ...Option) *http.ServeMux {
+	mux := http.NewServeMux()
+	NewHandler(monitoring.NewMonitor(nil, nil), options...).Register(mux)
+	return mux
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		expectedStatus int
+	}{
+		{
+			name:           "no token configured",
+			token:          "",
+			header:         "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing token",
+			token:          "secret",
+			header:         "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong token",
+			token:          "secret",
+			header:         "Bearer wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "valid token",
+			token:          "secret",
+			header:         "Bearer secret",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newTestMux(WithToken(tt.token))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownEndpoint(t *testing.T) {
+	mux := newTestMux()
+
+	for _, path := range []string{
+		"/api/v1/endpoints/unknown/check",
+		"/api/v1/namespaces/default/ingresses/unknown/check",
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("POST %s: expected status %d, got %d", path, http.StatusNotFound, rec.Code)
+		}
+	}
+}
+
+func TestCheckRequiresPost(t *testing.T) {
+	mux := newTestMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/endpoints/abc/check", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestListSkippedEmpty(t *testing.T) {
+	mux := newTestMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/skipped", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("Expected an empty list, got %s", body)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	h := NewHandler(nil, WithCheckRateLimit(time.Minute))
+
+	if wait := h.reserve("a"); wait != 0 {
+		t.Fatalf("Expected first reservation to succeed, got wait %v", wait)
+	}
+	if wait := h.reserve("a"); wait <= 0 {
+		t.Errorf("Expected second reservation of the same endpoint to be rate limited")
+	}
+	if wait := h.reserve("b"); wait != 0 {
+		t.Errorf("Expected reservation of a different endpoint to succeed, got wait %v", wait)
+	}
+
+	// A batch containing a limited endpoint must not reserve the others
+	if wait := h.reserve("a", "c"); wait <= 0 {
+		t.Errorf("Expected batch containing a limited endpoint to be rate limited")
+	}
+	if wait := h.reserve("c"); wait != 0 {
+		t.Errorf("Expected endpoint from rejected batch to remain available, got wait %v", wait)
+	}
+
+	// Released reservations can be made again
+	h.release("a")
+	if wait := h.reserve("a"); wait != 0 {
+		t.Errorf("Expected released endpoint to be available, got wait %v", wait)
+	}
+}
+
+func TestReservePrunesExpired(t *testing.T) {
+	h := NewHandler(nil, WithCheckRateLimit(time.Minute))
+	h.lastCheck["gone"] = time.Now().Add(-2 * time.Minute)
+	h.lastCheck["recent"] = time.Now().Add(-time.Second)
+
+	if wait := h.reserve("new"); wait != 0 {
+		t.Fatalf("Expected the reservation to succeed, got wait %v", wait)
+	}
+	if _, ok := h.lastCheck["gone"]; ok {
+		t.Errorf("Expected the expired reservation to be pruned")
+	}
+	if len(h.lastCheck) != 2 {
+		t.Errorf("Expected the recent and new reservations to be kept, got %v", h.lastCheck)
+	}
+}
+
+// fakeAPIServer serves the Ingresses of a cluster to the informers of a discovery
+// client. Other resources are served as empty lists and watches never send events.
+func fakeAPIServer(t *testing.T, ingresses ...networkingv1.Ingress) *httptest.Server {
+	t.Helper()
+
+	lists := map[string]interface{}{
+		"/apis/networking.k8s.io/v1/ingresses": &networkingv1.IngressList{
+			TypeMeta: metav1.TypeMeta{Kind: "IngressList", APIVersion: "networking.k8s.io/v1"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items:    ingresses,
+		},
+		"/apis/networking.k8s.io/v1/ingressclasses": &networkingv1.IngressClassList{
+			TypeMeta: metav1.TypeMeta{Kind: "IngressClassList", APIVersion: "networking.k8s.io/v1"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, ok := lists[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newClusterMonitor starts a monitor checking the Ingress app.example.com of a
+// fake cluster, served by the given backend, and waits for its first result
+func newClusterMonitor(t *testing.T, backend *httptest.Server) *monitoring.Monitor {
+	t.Helper()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+
+	// The rule has no host, so it is probed at the load balancer address, on the
+	// port of the host header
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	pathType := networkingv1.PathTypePrefix
+	ingress := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Namespace:   "default",
+			Annotations: map[string]string{discovery.AnnotationHostHeader: "app.example.com:" + port},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: "app",
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						}},
+					}},
+				}},
+			}},
+		},
+		Status: networkingv1.IngressStatus{
+			LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+				Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "127.0.0.1"}},
+			},
+		},
+	}
+
+	client, err := discovery.NewClientForCluster(discovery.ClusterOptions{
+		Name:      "test",
+		MasterURL: fakeAPIServer(t, ingress).URL,
+	})
+	if err != nil {
+		t.Fatalf("NewClientForCluster() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatalf("Discovery caches did not sync")
+	}
+
+	provider, err := metrics.NewProvider(ctx, "127.0.0.1:4317", time.Hour)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	monitor := monitoring.NewMonitor([]*discovery.Client{client}, provider, monitoring.WithCheckInterval(time.Hour))
+	monitor.Start(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if endpoints := monitor.Endpoints(); len(endpoints) == 1 && endpoints[0].LastResult != nil {
+			return monitor
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Endpoint was not checked, got %+v", monitor.Endpoints())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	var hang atomic.Bool
+	arrived := make(chan struct{}, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			arrived <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	monitor := newClusterMonitor(t, backend)
+	before := monitor.Endpoints()[0]
+	path := "/api/v1/endpoints/" + before.ID + "/check"
+
+	mux := http.NewServeMux()
+	NewHandler(monitor, WithCheckRateLimit(time.Minute)).Register(mux)
+
+	// A successful check returns its result
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var result monitoring.CheckResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding the check result: %v", err)
+	}
+	if result.ID != before.ID || !result.Up || result.StatusCode != http.StatusOK {
+		t.Errorf("Expected the endpoint to be up, got %+v", result)
+	}
+
+	// Checking it again right away is rate limited
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+
+	// A check abandoned by the client leaves the status untouched
+	recorded := monitor.Endpoints()[0].LastResult
+	hang.Store(true)
+	mux = http.NewServeMux()
+	NewHandler(monitor).Register(mux)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil).WithContext(ctx))
+	}()
+	<-arrived
+	cancel()
+	<-done
+
+	after := monitor.Endpoints()[0].LastResult
+	if !after.Up || !after.CheckedAt.Equal(recorded.CheckedAt) {
+		t.Errorf("Expected the cancelled check not to be recorded, got %+v", after)
+	}
+}
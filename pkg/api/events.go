@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/monitoring"
+)
+
+// Size of the per-client event buffer; slow clients miss events beyond this
+const eventBufferSize = 256
+
+// Interval between keep-alive comments on idle event streams
+const eventHeartbeatInterval = 30 * time.Second
+
+// eventFilter selects which events are sent to a client
+type eventFilter struct {
+	namespaces map[string]bool
+	types      map[monitoring.EventType]bool
+	selector   labels.Selector
+}
+
+// parseEventFilter builds an event filter from the request query parameters
+func parseEventFilter(r *http.Request) (*eventFilter, error) {
+	query := r.URL.Query()
+	filter := &eventFilter{
+		selector: labels.Everything(),
+	}
+
+	for _, ns := range splitQueryValues(query["namespace"]) {
+		if filter.namespaces == nil {
+			filter.namespaces = make(map[string]bool)
+		}
+		filter.namespaces[ns] = true
+	}
+
+	for _, eventType := range splitQueryValues(query["type"]) {
+		if filter.types == nil {
+			filter.types = make(map[monitoring.EventType]bool)
+		}
+		filter.types[monitoring.EventType(eventType)] = true
+	}
+
+	if selector := query.Get("labelSelector"); selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+		filter.selector = parsed
+	}
+
+	return filter, nil
+}
+
+// matches reports whether an event passes the filter
+func (f *eventFilter) matches(event monitoring.Event) bool {
+	if f.namespaces != nil && !f.namespaces[event.Result.Namespace] {
+		return false
+	}
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	return f.selector.Matches(labels.Set(event.Labels))
+}
+
+// splitQueryValues flattens repeated and comma-separated query values
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// streamEvents sends check results and state transitions as Server-Sent Events
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Subscribe before taking the snapshot so no result falls between the two
+	events, unsubscribe := h.monitor.Events().Subscribe(eventBufferSize)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Event streams are long-lived, so lift the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.Errorf("Error clearing write deadline for event stream: %v", err)
+	}
+
+	// Replay the current state of every endpoint
+	for _, endpoint := range h.monitor.Endpoints() {
+		if endpoint.LastResult == nil {
+			continue
+		}
+		event := monitoring.Event{
+			Type:   monitoring.EventState,
+			Time:   endpoint.LastResult.CheckedAt,
+			Labels: endpoint.Labels,
+			Result: *endpoint.LastResult,
+		}
+		if !filter.matches(event) {
+			continue
+		}
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logging.Warnf("Event stream does not support flushing: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a single event in the Server-Sent Events wire format
+func writeEvent(w http.ResponseWriter, event monitoring.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/monitoring"
+)
+
+func TestEventFilter(t *testing.T) {
+	event := monitoring.Event{
+		Type:   monitoring.EventTransition,
+		Labels: map[string]string{"team": "payments", "tier": "public"},
+		Result: monitoring.CheckResult{Namespace: "shop"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{"no filter", "", true},
+		{"matching namespace", "?namespace=shop", true},
+		{"namespace list", "?namespace=default,shop", true},
+		{"other namespace", "?namespace=default", false},
+		{"matching type", "?type=transition", true},
+		{"other type", "?type=check", false},
+		{"matching label selector", "?labelSelector=team%3Dpayments", true},
+		{"set based label selector", "?labelSelector=tier+in+(public,edge)", true},
+		{"excluding label selector", "?labelSelector=tier!%3Dpublic", false},
+		{"combined filters", "?namespace=shop&type=transition&labelSelector=team", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseEventFilter(httptest.NewRequest("GET", "/api/v1/events"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("parseEventFilter() error = %v", err)
+			}
+			if result := filter.matches(event); result != tt.expected {
+				t.Errorf("matches() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEventFilterInvalidSelector(t *testing.T) {
+	if _, err := parseEventFilter(httptest.NewRequest("GET", "/api/v1/events?labelSelector=%3D%3D%3D", nil)); err == nil {
+		t.Errorf("Expected an error for an invalid label selector")
+	}
+}
+
+// readEvent reads the next event from a Server-Sent Events stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) (string, monitoring.Event) {
+	t.Helper()
+	var eventType string
+	var event monitoring.Event
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading the event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && eventType != "":
+			return eventType, event
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Error decoding event data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	monitor := newClusterMonitor(t, backend)
+	endpoint := monitor.Endpoints()[0]
+	subscribers := monitor.Events().Subscribers()
+
+	mux := http.NewServeMux()
+	NewHandler(monitor).Register(mux)
+	// Signal when a stream handler returns, so that its cleanup can be checked
+	finished := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		finished <- struct{}{}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error opening the event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected content type text/event-stream, got %q", contentType)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	// The current state of every endpoint is replayed first
+	eventType, event := readEvent(t, reader)
+	if eventType != string(monitoring.EventState) || event.Type != monitoring.EventState {
+		t.Errorf("Expected a %s event, got %s", monitoring.EventState, eventType)
+	}
+	if event.Result.ID != endpoint.ID || !event.Result.Up {
+		t.Errorf("Expected the state of endpoint %s to be up, got %+v", endpoint.ID, event.Result)
+	}
+	if got := monitor.Events().Subscribers(); got != subscribers+1 {
+		t.Errorf("Expected the stream to subscribe, got %d subscribers", got)
+	}
+
+	// Published transitions are delivered as they happen
+	previousUp := true
+	result := event.Result
+	result.Up = false
+	monitor.Events().Publish(monitoring.Event{
+		Type:       monitoring.EventTransition,
+		Time:       time.Now(),
+		Result:     result,
+		PreviousUp: &previousUp,
+	})
+	eventType, event = readEvent(t, reader)
+	if eventType != string(monitoring.EventTransition) || event.Result.ID != endpoint.ID || event.Result.Up {
+		t.Errorf("Expected the transition of endpoint %s to down, got %s %+v", endpoint.ID, eventType, event)
+	}
+	if event.PreviousUp == nil || !*event.PreviousUp {
+		t.Errorf("Expected the transition from up, got %v", event.PreviousUp)
+	}
+
+	// Disconnecting ends the stream and removes its subscription
+	cancel()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream handler did not return after the client disconnected")
+	}
+	if got := monitor.Events().Subscribers(); got != subscribers {
+		t.Errorf("Expected %d subscribers after the client disconnected, got %d", subscribers, got)
+	}
+}
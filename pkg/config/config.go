@@ -1,152 +1,830 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"io/fs"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	MonitoringInterval time.Duration
-	MetricsInterval    time.Duration
-	OtelCollectorURL   string
-	SuccessStatusCodes []int
-	NamespaceMode      string // "allow" or "deny"
-	Namespaces         []string
+	MonitoringInterval  time.Duration
+	CheckTimeout        time.Duration
+	MetricsInterval     time.Duration
+	OtelCollectorURL    string
+	SuccessStatusCodes  []int
+	VerifyHTTPSRedirect bool   // Check that the http URL of https endpoints redirects to https
+	ProbeMode           string // "dns", "loadbalancer" or "controller"
+	ControllerAddress   string // Address of the ingress controller probed in controller mode
+	ProbeBackends       bool   // Also probe the backend Service of every endpoint directly
+	BackendResolution   string // "clusterip" or "endpointslices"
+	ProbePods           bool   // Allow Ingresses to opt into probing every pod of their backends
+	NamespaceMode       string // "allow" or "deny"
+	Namespaces          []string
+	AllowNamespaces     []string // Patterns of namespaces to discover, all when empty
+	DenyNamespaces      []string // Patterns of namespaces to skip even when allowed
+	Selectors           SelectorsConfig
+	IngressClasses      []string // Classes of Ingresses to discover, all when empty
+	IngressClassConfigs map[string]IngressClassConfig
+	ServiceDiscovery    bool   // Also discover Services annotated with health.monitor/* annotations
+	ClusterDomain       string // DNS domain of Services, for Service discovery
+	Sources             SourcesConfig
+	APIToken            string
+	APICheckRateLimit   time.Duration
+	ServerAddress       string
+	ServerReadTimeout   time.Duration
+	ServerWriteTimeout  time.Duration
+	TLSCertFile         string
+	TLSKeyFile          string
+	ShutdownGracePeriod time.Duration
+	LeaderElection      LeaderElectionConfig
+	Sharding            ShardingConfig
+	Location            LocationConfig
+	Clusters            []ClusterConfig
+	File                string // Path of the loaded config file, empty when none was found
+}
+
+// SelectorsConfig restricts which Ingresses are discovered using Kubernetes selectors
+type SelectorsConfig struct {
+	IngressLabels   string
+	IngressFields   string
+	NamespaceLabels string
+}
+
+// SourcesConfig enables discovery from routing resources other than Ingresses,
+// each only when the cluster serves its API
+type SourcesConfig struct {
+	Routes          bool // OpenShift Routes
+	VirtualServices bool // Istio VirtualServices bound to gateways
+	IngressRoutes   bool // Traefik IngressRoutes
+}
+
+// IngressClassConfig overrides how the endpoints of an IngressClass are probed
+type IngressClassConfig struct {
+	Scheme       string `yaml:"scheme"`       // "http" or "https"
+	ProbeAddress string `yaml:"probeAddress"` // Host to connect to instead of the URL host, on the port of the URL
+}
+
+// ClusterConfig describes a cluster to discover endpoints in
+type ClusterConfig struct {
+	Name       string
+	Kubeconfig string
+	Context    string
+	InCluster  bool
+}
+
+// LocationConfig identifies where this monitor probes from
+type LocationConfig struct {
+	Region  string
+	Zone    string
+	Cluster string
+	// Home is false when the monitor runs outside the cluster that serves the
+	// endpoints, in which case only hosts matching RemoteHostPatterns are probed
+	Home               bool
+	RemoteHostPatterns []string
+}
+
+// ShardingConfig holds the settings for splitting endpoints across replicas
+type ShardingConfig struct {
+	Enabled       bool
+	PeerService   string // Headless Service selecting the monitor's pods
+	PeerNamespace string
+}
+
+// LeaderElectionConfig holds the Lease-based leader election settings
+type LeaderElectionConfig struct {
+	Enabled        bool
+	LeaseName      string
+	LeaseNamespace string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
 }
 
 // ConfigFile represents the structure of the YAML config file
 type ConfigFile struct {
 	Monitoring struct {
-		Interval           int   `yaml:"interval"`
-		SuccessStatusCodes []int `yaml:"successStatusCodes"`
+		Interval            Duration `yaml:"interval"`
+		Timeout             Duration `yaml:"timeout"`
+		SuccessStatusCodes  []int    `yaml:"successStatusCodes"`
+		VerifyHTTPSRedirect bool     `yaml:"verifyHTTPSRedirect"`
+		ProbeMode           string   `yaml:"probeMode"`
+		ControllerAddress   string   `yaml:"controllerAddress"`
+		ProbeBackends       bool     `yaml:"probeBackends"`
+		BackendResolution   string   `yaml:"backendResolution"`
+		ProbePods           bool     `yaml:"probePods"`
 	} `yaml:"monitoring"`
 	Metrics struct {
-		Interval         int    `yaml:"interval"`
-		OtelCollectorURL string `yaml:"otelCollectorURL"`
+		Interval         Duration `yaml:"interval"`
+		OtelCollectorURL string   `yaml:"otelCollectorURL"`
 	} `yaml:"metrics"`
 	Discovery struct {
-		NamespaceMode string   `yaml:"namespaceMode"`
-		Namespaces    []string `yaml:"namespaces"`
+		NamespaceMode          string                        `yaml:"namespaceMode"`
+		Namespaces             []string                      `yaml:"namespaces"`
+		AllowNamespaces        []string                      `yaml:"allowNamespaces"`
+		DenyNamespaces         []string                      `yaml:"denyNamespaces"`
+		IngressLabelSelector   string                        `yaml:"ingressLabelSelector"`
+		IngressFieldSelector   string                        `yaml:"ingressFieldSelector"`
+		NamespaceLabelSelector string                        `yaml:"namespaceLabelSelector"`
+		IngressClasses         []string                      `yaml:"ingressClasses"`
+		IngressClassSettings   map[string]IngressClassConfig `yaml:"ingressClassSettings"`
+		ServiceDiscovery       bool                          `yaml:"serviceDiscovery"`
+		ClusterDomain          string                        `yaml:"clusterDomain"`
+		Sources                struct {
+			Routes          *bool `yaml:"routes"`
+			VirtualServices bool  `yaml:"virtualServices"`
+			IngressRoutes   bool  `yaml:"ingressRoutes"`
+		} `yaml:"sources"`
 	} `yaml:"discovery"`
+	API struct {
+		Token          string   `yaml:"token"`
+		CheckRateLimit Duration `yaml:"checkRateLimit"`
+	} `yaml:"api"`
+	Server struct {
+		Address             string   `yaml:"address"`
+		ReadTimeout         Duration `yaml:"readTimeout"`
+		WriteTimeout        Duration `yaml:"writeTimeout"`
+		TLSCertFile         string   `yaml:"tlsCertFile"`
+		TLSKeyFile          string   `yaml:"tlsKeyFile"`
+		ShutdownGracePeriod Duration `yaml:"shutdownGracePeriod"`
+	} `yaml:"server"`
+	LeaderElection struct {
+		Enabled        bool     `yaml:"enabled"`
+		LeaseName      string   `yaml:"leaseName"`
+		LeaseNamespace string   `yaml:"leaseNamespace"`
+		LeaseDuration  Duration `yaml:"leaseDuration"`
+		RenewDeadline  Duration `yaml:"renewDeadline"`
+		RetryPeriod    Duration `yaml:"retryPeriod"`
+	} `yaml:"leaderElection"`
+	Sharding struct {
+		Enabled       bool   `yaml:"enabled"`
+		PeerService   string `yaml:"peerService"`
+		PeerNamespace string `yaml:"peerNamespace"`
+	} `yaml:"sharding"`
+	Location struct {
+		Region             string   `yaml:"region"`
+		Zone               string   `yaml:"zone"`
+		Cluster            string   `yaml:"cluster"`
+		Home               *bool    `yaml:"home"`
+		RemoteHostPatterns []string `yaml:"remoteHostPatterns"`
+	} `yaml:"location"`
+	Clusters []struct {
+		Name       string `yaml:"name"`
+		Kubeconfig string `yaml:"kubeconfig"`
+		Context    string `yaml:"context"`
+		InCluster  bool   `yaml:"inCluster"`
+	} `yaml:"clusters"`
 }
 
 // Default configuration values
 const (
 	DefaultConfigFile         = "config.yaml"
+	DefaultConfigDir          = "/etc/k8s-http-monitor"
 	DefaultMonitoringInterval = 30 * time.Second
+	DefaultCheckTimeout       = 10 * time.Second
 	DefaultMetricsInterval    = 10 * time.Second
 	DefaultOtelCollectorURL   = "signoz-otel-collector:4317"
 	DefaultNamespaceMode      = "allow" // "allow" means allow all namespaces by default
+	DefaultProbeMode          = "dns"
+	DefaultBackendResolution  = "clusterip"
+	DefaultClusterDomain      = discovery.DefaultClusterDomain
+	DefaultAPICheckRateLimit  = 10 * time.Second
+	DefaultServerAddress      = ":8080"
+	DefaultServerReadTimeout  = 10 * time.Second
+	DefaultServerWriteTimeout = 30 * time.Second
+	DefaultShutdownGrace      = 25 * time.Second // Below the 30s default terminationGracePeriodSeconds
+	DefaultLeaseName          = "k8s-http-monitor"
+	DefaultLeaseNamespace     = "default"
+	DefaultLeaseDuration      = 15 * time.Second
+	DefaultRenewDeadline      = 10 * time.Second
+	DefaultRetryPeriod        = 2 * time.Second
+	DefaultPeerService        = "k8s-http-monitor-peers"
 )
 
 // Default success status codes (401, 403, 404 are considered successful by default)
 var DefaultSuccessStatusCodes = []int{401, 403, 404}
 
+// ConfigSearchPaths lists where the config file is looked up, in order, when no
+// path is given explicitly
+var ConfigSearchPaths = []string{
+	filepath.Join(DefaultConfigDir, DefaultConfigFile),
+	DefaultConfigFile,
+}
+
 // Environment variable names
 const (
+	EnvConfigFile         = "CONFIG_FILE"
 	EnvMonitoringInterval = "MONITOR_INTERVAL_SECONDS"
+	EnvMonitorTimeout     = "MONITOR_TIMEOUT_SECONDS"
 	EnvMetricsInterval    = "METRICS_INTERVAL_SECONDS"
 	EnvOtelCollectorURL   = "OTEL_COLLECTOR_URL"
 	EnvSuccessStatusCodes = "SUCCESS_STATUS_CODES"
+	EnvVerifyRedirect     = "VERIFY_HTTPS_REDIRECT"
+	EnvProbeMode          = "PROBE_MODE"
+	EnvControllerAddress  = "CONTROLLER_ADDRESS"
+	EnvProbeBackends      = "PROBE_BACKENDS"
+	EnvBackendResolution  = "BACKEND_RESOLUTION"
+	EnvProbePods          = "PROBE_PODS"
 	EnvNamespaceMode      = "NAMESPACE_MODE"
 	EnvNamespaces         = "NAMESPACES"
+	EnvAllowNamespaces    = "ALLOW_NAMESPACES"
+	EnvDenyNamespaces     = "DENY_NAMESPACES"
+	EnvIngressLabels      = "INGRESS_LABEL_SELECTOR"
+	EnvIngressFields      = "INGRESS_FIELD_SELECTOR"
+	EnvNamespaceLabels    = "NAMESPACE_LABEL_SELECTOR"
+	EnvIngressClasses     = "INGRESS_CLASSES"
+	EnvServiceDiscovery   = "SERVICE_DISCOVERY"
+	EnvClusterDomain      = "CLUSTER_DOMAIN"
+	EnvRouteDiscovery     = "ROUTE_DISCOVERY"
+	EnvVirtualServices    = "VIRTUALSERVICE_DISCOVERY"
+	EnvIngressRoutes      = "INGRESSROUTE_DISCOVERY"
+	EnvAPIToken           = "API_TOKEN"
+	EnvAPICheckRateLimit  = "API_CHECK_RATE_LIMIT_SECONDS"
+	EnvServerAddress      = "SERVER_ADDRESS"
+	EnvServerReadTimeout  = "SERVER_READ_TIMEOUT_SECONDS"
+	EnvServerWriteTimeout = "SERVER_WRITE_TIMEOUT_SECONDS"
+	EnvTLSCertFile        = "TLS_CERT_FILE"
+	EnvTLSKeyFile         = "TLS_KEY_FILE"
+	EnvShutdownGrace      = "SHUTDOWN_GRACE_PERIOD_SECONDS"
+	EnvLeaderElection     = "LEADER_ELECTION_ENABLED"
+	EnvLeaseName          = "LEADER_ELECTION_LEASE_NAME"
+	EnvLeaseNamespace     = "LEADER_ELECTION_NAMESPACE"
+	EnvPodNamespace       = "POD_NAMESPACE"
+	EnvSharding           = "SHARDING_ENABLED"
+	EnvPeerService        = "SHARDING_PEER_SERVICE"
+	EnvPeerNamespace      = "SHARDING_NAMESPACE"
+	EnvProbeRegion        = "PROBE_REGION"
+	EnvProbeZone          = "PROBE_ZONE"
+	EnvProbeCluster       = "PROBE_CLUSTER"
+	EnvProbeHome          = "PROBE_HOME_CLUSTER"
+	EnvRemoteHostPatterns = "PROBE_REMOTE_HOST_PATTERNS"
 )
 
-// LoadConfig loads the configuration from file and environment variables
+// LoadConfig loads the configuration from the file named by CONFIG_FILE, or the
+// first file found in the search paths, and environment variables
 func LoadConfig() (*Config, error) {
+	return LoadConfigFrom("")
+}
+
+// LoadConfigFrom loads the configuration from the given file and environment
+// variables. An empty path falls back to CONFIG_FILE and then the search paths.
+// A file that was named explicitly must exist and be readable.
+func LoadConfigFrom(path string) (*Config, error) {
 	// Set default configuration
 	config := &Config{
 		MonitoringInterval: DefaultMonitoringInterval,
+		CheckTimeout:       DefaultCheckTimeout,
 		MetricsInterval:    DefaultMetricsInterval,
 		OtelCollectorURL:   DefaultOtelCollectorURL,
 		SuccessStatusCodes: DefaultSuccessStatusCodes,
 		NamespaceMode:      DefaultNamespaceMode,
-		Namespaces:         []string{},
+		ProbeMode:          DefaultProbeMode,
+		BackendResolution:  DefaultBackendResolution,
+		ClusterDomain:      DefaultClusterDomain,
+		Sources: SourcesConfig{
+			Routes: true,
+		},
+		Namespaces:          []string{},
+		APICheckRateLimit:   DefaultAPICheckRateLimit,
+		ServerAddress:       DefaultServerAddress,
+		ServerReadTimeout:   DefaultServerReadTimeout,
+		ServerWriteTimeout:  DefaultServerWriteTimeout,
+		ShutdownGracePeriod: DefaultShutdownGrace,
+		LeaderElection: LeaderElectionConfig{
+			LeaseName:      DefaultLeaseName,
+			LeaseNamespace: DefaultLeaseNamespace,
+			LeaseDuration:  DefaultLeaseDuration,
+			RenewDeadline:  DefaultRenewDeadline,
+			RetryPeriod:    DefaultRetryPeriod,
+		},
+		Sharding: ShardingConfig{
+			PeerService:   DefaultPeerService,
+			PeerNamespace: DefaultLeaseNamespace,
+		},
+		Location: LocationConfig{
+			Home: true,
+		},
+	}
+
+	// Default the lease and peer namespaces to the pod's own namespace when known
+	if podNamespace := os.Getenv(EnvPodNamespace); podNamespace != "" {
+		config.LeaderElection.LeaseNamespace = podNamespace
+		config.Sharding.PeerNamespace = podNamespace
 	}
 
-	// Try to read config file
+	// Read the config file
 	configFile := &ConfigFile{}
-	configData, err := ioutil.ReadFile(DefaultConfigFile)
-	if err == nil {
-		if err := yaml.Unmarshal(configData, configFile); err != nil {
-			return nil, fmt.Errorf("error parsing config file: %w", err)
+	configData, configPath, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if configData != nil {
+		config.File = configPath
+		decoder := yaml.NewDecoder(bytes.NewReader(configData))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(configFile); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing config file %s: %w", configPath, err)
 		}
 
 		// Apply config file values
-		if configFile.Monitoring.Interval > 0 {
-			config.MonitoringInterval = time.Duration(configFile.Monitoring.Interval) * time.Second
+		if configFile.Monitoring.Interval != 0 {
+			config.MonitoringInterval = time.Duration(configFile.Monitoring.Interval)
+		}
+		if configFile.Monitoring.Timeout != 0 {
+			config.CheckTimeout = time.Duration(configFile.Monitoring.Timeout)
 		}
 		if len(configFile.Monitoring.SuccessStatusCodes) > 0 {
 			config.SuccessStatusCodes = configFile.Monitoring.SuccessStatusCodes
 		}
-		if configFile.Metrics.Interval > 0 {
-			config.MetricsInterval = time.Duration(configFile.Metrics.Interval) * time.Second
+		if configFile.Monitoring.VerifyHTTPSRedirect {
+			config.VerifyHTTPSRedirect = true
+		}
+		if configFile.Monitoring.ProbeMode != "" {
+			config.ProbeMode = strings.ToLower(configFile.Monitoring.ProbeMode)
+		}
+		if configFile.Monitoring.ControllerAddress != "" {
+			config.ControllerAddress = configFile.Monitoring.ControllerAddress
+		}
+		if configFile.Monitoring.ProbeBackends {
+			config.ProbeBackends = true
+		}
+		if configFile.Monitoring.ProbePods {
+			config.ProbePods = true
+		}
+		if configFile.Monitoring.BackendResolution != "" {
+			config.BackendResolution = strings.ToLower(configFile.Monitoring.BackendResolution)
+		}
+		if configFile.Metrics.Interval != 0 {
+			config.MetricsInterval = time.Duration(configFile.Metrics.Interval)
 		}
 		if configFile.Metrics.OtelCollectorURL != "" {
 			config.OtelCollectorURL = configFile.Metrics.OtelCollectorURL
 		}
 		if configFile.Discovery.NamespaceMode != "" {
-			config.NamespaceMode = configFile.Discovery.NamespaceMode
+			config.NamespaceMode = strings.ToLower(configFile.Discovery.NamespaceMode)
 		}
 		if len(configFile.Discovery.Namespaces) > 0 {
 			config.Namespaces = configFile.Discovery.Namespaces
 		}
-	}
-
-	// Override with environment variables if set
-	if envInterval := os.Getenv(EnvMonitoringInterval); envInterval != "" {
-		if seconds, err := strconv.Atoi(envInterval); err == nil && seconds > 0 {
-			config.MonitoringInterval = time.Duration(seconds) * time.Second
+		if len(configFile.Discovery.AllowNamespaces) > 0 {
+			config.AllowNamespaces = configFile.Discovery.AllowNamespaces
 		}
-	}
-	if envInterval := os.Getenv(EnvMetricsInterval); envInterval != "" {
-		if seconds, err := strconv.Atoi(envInterval); err == nil && seconds > 0 {
-			config.MetricsInterval = time.Duration(seconds) * time.Second
+		if len(configFile.Discovery.DenyNamespaces) > 0 {
+			config.DenyNamespaces = configFile.Discovery.DenyNamespaces
+		}
+		if configFile.Discovery.IngressLabelSelector != "" {
+			config.Selectors.IngressLabels = configFile.Discovery.IngressLabelSelector
+		}
+		if configFile.Discovery.IngressFieldSelector != "" {
+			config.Selectors.IngressFields = configFile.Discovery.IngressFieldSelector
+		}
+		if configFile.Discovery.NamespaceLabelSelector != "" {
+			config.Selectors.NamespaceLabels = configFile.Discovery.NamespaceLabelSelector
+		}
+		if len(configFile.Discovery.IngressClasses) > 0 {
+			config.IngressClasses = configFile.Discovery.IngressClasses
+		}
+		if len(configFile.Discovery.IngressClassSettings) > 0 {
+			config.IngressClassConfigs = configFile.Discovery.IngressClassSettings
+		}
+		if configFile.Discovery.ServiceDiscovery {
+			config.ServiceDiscovery = true
+		}
+		if configFile.Discovery.ClusterDomain != "" {
+			config.ClusterDomain = strings.TrimSuffix(configFile.Discovery.ClusterDomain, ".")
+		}
+		if configFile.Discovery.Sources.Routes != nil {
+			config.Sources.Routes = *configFile.Discovery.Sources.Routes
+		}
+		if configFile.Discovery.Sources.VirtualServices {
+			config.Sources.VirtualServices = true
+		}
+		if configFile.Discovery.Sources.IngressRoutes {
+			config.Sources.IngressRoutes = true
+		}
+		if configFile.API.Token != "" {
+			config.APIToken = configFile.API.Token
+		}
+		if configFile.API.CheckRateLimit != 0 {
+			config.APICheckRateLimit = time.Duration(configFile.API.CheckRateLimit)
+		}
+		if configFile.Server.Address != "" {
+			config.ServerAddress = configFile.Server.Address
+		}
+		if configFile.Server.ReadTimeout != 0 {
+			config.ServerReadTimeout = time.Duration(configFile.Server.ReadTimeout)
+		}
+		if configFile.Server.WriteTimeout != 0 {
+			config.ServerWriteTimeout = time.Duration(configFile.Server.WriteTimeout)
+		}
+		if configFile.Server.TLSCertFile != "" {
+			config.TLSCertFile = configFile.Server.TLSCertFile
+		}
+		if configFile.Server.TLSKeyFile != "" {
+			config.TLSKeyFile = configFile.Server.TLSKeyFile
+		}
+		if configFile.Server.ShutdownGracePeriod != 0 {
+			config.ShutdownGracePeriod = time.Duration(configFile.Server.ShutdownGracePeriod)
+		}
+		if configFile.LeaderElection.Enabled {
+			config.LeaderElection.Enabled = true
+		}
+		if configFile.LeaderElection.LeaseName != "" {
+			config.LeaderElection.LeaseName = configFile.LeaderElection.LeaseName
+		}
+		if configFile.LeaderElection.LeaseNamespace != "" {
+			config.LeaderElection.LeaseNamespace = configFile.LeaderElection.LeaseNamespace
+		}
+		if configFile.LeaderElection.LeaseDuration != 0 {
+			config.LeaderElection.LeaseDuration = time.Duration(configFile.LeaderElection.LeaseDuration)
+		}
+		if configFile.LeaderElection.RenewDeadline != 0 {
+			config.LeaderElection.RenewDeadline = time.Duration(configFile.LeaderElection.RenewDeadline)
+		}
+		if configFile.LeaderElection.RetryPeriod != 0 {
+			config.LeaderElection.RetryPeriod = time.Duration(configFile.LeaderElection.RetryPeriod)
+		}
+		if configFile.Sharding.Enabled {
+			config.Sharding.Enabled = true
+		}
+		if configFile.Sharding.PeerService != "" {
+			config.Sharding.PeerService = configFile.Sharding.PeerService
+		}
+		if configFile.Sharding.PeerNamespace != "" {
+			config.Sharding.PeerNamespace = configFile.Sharding.PeerNamespace
+		}
+		if configFile.Location.Region != "" {
+			config.Location.Region = configFile.Location.Region
+		}
+		if configFile.Location.Zone != "" {
+			config.Location.Zone = configFile.Location.Zone
+		}
+		if configFile.Location.Cluster != "" {
+			config.Location.Cluster = configFile.Location.Cluster
+		}
+		if configFile.Location.Home != nil {
+			config.Location.Home = *configFile.Location.Home
+		}
+		if len(configFile.Location.RemoteHostPatterns) > 0 {
+			config.Location.RemoteHostPatterns = configFile.Location.RemoteHostPatterns
+		}
+		for _, cluster := range configFile.Clusters {
+			config.Clusters = append(config.Clusters, ClusterConfig{
+				Name:       cluster.Name,
+				Kubeconfig: cluster.Kubeconfig,
+				Context:    cluster.Context,
+				InCluster:  cluster.InCluster,
+			})
 		}
 	}
+
+	// Override with environment variables if set
+	var errs []error
+	errs = append(errs,
+		parseEnvDuration(EnvMonitoringInterval, &config.MonitoringInterval),
+		parseEnvDuration(EnvMonitorTimeout, &config.CheckTimeout),
+		parseEnvDuration(EnvMetricsInterval, &config.MetricsInterval),
+	)
 	if envURL := os.Getenv(EnvOtelCollectorURL); envURL != "" {
 		config.OtelCollectorURL = envURL
 	}
 
 	// Parse success status codes from environment variable
 	if envStatusCodes := os.Getenv(EnvSuccessStatusCodes); envStatusCodes != "" {
-		// Split by comma
-		statusCodeStrs := strings.Split(envStatusCodes, ",")
 		var statusCodes []int
-		for _, codeStr := range statusCodeStrs {
-			if code, err := strconv.Atoi(strings.TrimSpace(codeStr)); err == nil {
-				statusCodes = append(statusCodes, code)
+		for _, codeStr := range splitEnvList(envStatusCodes) {
+			code, err := strconv.Atoi(codeStr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid status code %q", EnvSuccessStatusCodes, codeStr))
+				continue
 			}
+			statusCodes = append(statusCodes, code)
 		}
 		if len(statusCodes) > 0 {
 			config.SuccessStatusCodes = statusCodes
 		}
 	}
 
+	errs = append(errs, parseEnvBool(EnvVerifyRedirect, &config.VerifyHTTPSRedirect))
+	if envMode := os.Getenv(EnvProbeMode); envMode != "" {
+		config.ProbeMode = strings.ToLower(strings.TrimSpace(envMode))
+	}
+	if envAddress := os.Getenv(EnvControllerAddress); envAddress != "" {
+		config.ControllerAddress = envAddress
+	}
+	errs = append(errs, parseEnvBool(EnvProbeBackends, &config.ProbeBackends))
+	errs = append(errs, parseEnvBool(EnvProbePods, &config.ProbePods))
+	if envResolution := os.Getenv(EnvBackendResolution); envResolution != "" {
+		config.BackendResolution = strings.ToLower(strings.TrimSpace(envResolution))
+	}
+	errs = append(errs, parseEnvBool(EnvServiceDiscovery, &config.ServiceDiscovery))
+	errs = append(errs, parseEnvBool(EnvRouteDiscovery, &config.Sources.Routes))
+	errs = append(errs, parseEnvBool(EnvVirtualServices, &config.Sources.VirtualServices))
+	errs = append(errs, parseEnvBool(EnvIngressRoutes, &config.Sources.IngressRoutes))
+	if envDomain := os.Getenv(EnvClusterDomain); envDomain != "" {
+		config.ClusterDomain = strings.TrimSuffix(strings.TrimSpace(envDomain), ".")
+	}
+
 	// Parse namespace mode from environment variable
 	if envMode := os.Getenv(EnvNamespaceMode); envMode != "" {
-		mode := strings.ToLower(envMode)
-		if mode == "allow" || mode == "deny" {
-			config.NamespaceMode = mode
-		}
+		config.NamespaceMode = strings.ToLower(strings.TrimSpace(envMode))
 	}
 
 	// Parse namespaces from environment variable
 	if envNamespaces := os.Getenv(EnvNamespaces); envNamespaces != "" {
-		// Split by comma
-		namespaces := strings.Split(envNamespaces, ",")
-		for i, ns := range namespaces {
-			namespaces[i] = strings.TrimSpace(ns)
-		}
-		config.Namespaces = namespaces
+		config.Namespaces = splitEnvList(envNamespaces)
+	}
+
+	// Parse namespace patterns from environment variables
+	if envPatterns := os.Getenv(EnvAllowNamespaces); envPatterns != "" {
+		config.AllowNamespaces = splitEnvList(envPatterns)
+	}
+	if envPatterns := os.Getenv(EnvDenyNamespaces); envPatterns != "" {
+		config.DenyNamespaces = splitEnvList(envPatterns)
+	}
+
+	// Parse discovery selectors from environment variables
+	if envSelector := os.Getenv(EnvIngressLabels); envSelector != "" {
+		config.Selectors.IngressLabels = envSelector
+	}
+	if envSelector := os.Getenv(EnvIngressFields); envSelector != "" {
+		config.Selectors.IngressFields = envSelector
+	}
+	if envSelector := os.Getenv(EnvNamespaceLabels); envSelector != "" {
+		config.Selectors.NamespaceLabels = envSelector
+	}
+
+	// Parse IngressClasses from environment variable
+	if envClasses := os.Getenv(EnvIngressClasses); envClasses != "" {
+		config.IngressClasses = splitEnvList(envClasses)
+	}
+
+	// API settings
+	if envToken := os.Getenv(EnvAPIToken); envToken != "" {
+		config.APIToken = envToken
+	}
+	errs = append(errs, parseEnvDuration(EnvAPICheckRateLimit, &config.APICheckRateLimit))
+
+	// Server settings
+	if envAddress := os.Getenv(EnvServerAddress); envAddress != "" {
+		config.ServerAddress = envAddress
+	}
+	errs = append(errs,
+		parseEnvDuration(EnvServerReadTimeout, &config.ServerReadTimeout),
+		parseEnvDuration(EnvServerWriteTimeout, &config.ServerWriteTimeout),
+	)
+	if envCert := os.Getenv(EnvTLSCertFile); envCert != "" {
+		config.TLSCertFile = envCert
+	}
+	if envKey := os.Getenv(EnvTLSKeyFile); envKey != "" {
+		config.TLSKeyFile = envKey
+	}
+	errs = append(errs, parseEnvDuration(EnvShutdownGrace, &config.ShutdownGracePeriod))
+
+	// Leader election settings
+	errs = append(errs, parseEnvBool(EnvLeaderElection, &config.LeaderElection.Enabled))
+	if envName := os.Getenv(EnvLeaseName); envName != "" {
+		config.LeaderElection.LeaseName = envName
+	}
+	if envNamespace := os.Getenv(EnvLeaseNamespace); envNamespace != "" {
+		config.LeaderElection.LeaseNamespace = envNamespace
+	}
+
+	// Sharding settings
+	errs = append(errs, parseEnvBool(EnvSharding, &config.Sharding.Enabled))
+	if envService := os.Getenv(EnvPeerService); envService != "" {
+		config.Sharding.PeerService = envService
+	}
+	if envNamespace := os.Getenv(EnvPeerNamespace); envNamespace != "" {
+		config.Sharding.PeerNamespace = envNamespace
+	}
+
+	// Probe location settings
+	if envRegion := os.Getenv(EnvProbeRegion); envRegion != "" {
+		config.Location.Region = envRegion
+	}
+	if envZone := os.Getenv(EnvProbeZone); envZone != "" {
+		config.Location.Zone = envZone
+	}
+	if envCluster := os.Getenv(EnvProbeCluster); envCluster != "" {
+		config.Location.Cluster = envCluster
+	}
+	errs = append(errs, parseEnvBool(EnvProbeHome, &config.Location.Home))
+	if envPatterns := os.Getenv(EnvRemoteHostPatterns); envPatterns != "" {
+		config.Location.RemoteHostPatterns = splitEnvList(envPatterns)
+	}
+
+	// Report every problem at once rather than one per restart
+	errs = append(errs, config.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
+
+// Validate checks that the configuration is consistent and returns all problems found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.MonitoringInterval <= 0 {
+		errs = append(errs, fmt.Errorf("monitoring interval must be positive, got %v", c.MonitoringInterval))
+	}
+	if c.CheckTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("check timeout must be positive, got %v", c.CheckTimeout))
+	} else if c.MonitoringInterval > 0 && c.MonitoringInterval <= c.CheckTimeout {
+		errs = append(errs, fmt.Errorf("monitoring interval %v must be longer than the check timeout %v", c.MonitoringInterval, c.CheckTimeout))
+	}
+	if c.MetricsInterval <= 0 {
+		errs = append(errs, fmt.Errorf("metrics interval must be positive, got %v", c.MetricsInterval))
+	}
+	for _, code := range c.SuccessStatusCodes {
+		if code < 100 || code > 599 {
+			errs = append(errs, fmt.Errorf("success status code %d is outside the range 100-599", code))
+		}
+	}
+	if c.NamespaceMode != "allow" && c.NamespaceMode != "deny" {
+		errs = append(errs, fmt.Errorf("namespace mode %q is unknown, expected allow or deny", c.NamespaceMode))
+	}
+	for _, patterns := range [][]string{c.Namespaces, c.AllowNamespaces, c.DenyNamespaces} {
+		if err := discovery.ValidateNamespacePatterns(patterns); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	selectors := discovery.Selectors{
+		IngressLabels:   c.Selectors.IngressLabels,
+		IngressFields:   c.Selectors.IngressFields,
+		NamespaceLabels: c.Selectors.NamespaceLabels,
+	}
+	if err := selectors.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if mode, err := discovery.ParseProbeMode(c.ProbeMode); err != nil {
+		errs = append(errs, err)
+	} else if mode == discovery.ProbeModeController && c.ControllerAddress == "" {
+		errs = append(errs, errors.New("probe mode controller requires a controller address"))
+	}
+	if _, err := discovery.ParseBackendResolution(c.BackendResolution); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.ContainsAny(c.ClusterDomain, ":/ ") {
+		errs = append(errs, fmt.Errorf("cluster domain %q is invalid", c.ClusterDomain))
+	}
+	if c.ControllerAddress != "" && !validProbeAddress(c.ControllerAddress) {
+		errs = append(errs, fmt.Errorf("controller address %q is invalid, expected a host without a port", c.ControllerAddress))
+	}
+	for class, settings := range c.IngressClassConfigs {
+		if settings.Scheme != "" && settings.Scheme != "http" && settings.Scheme != "https" {
+			errs = append(errs, fmt.Errorf("ingress class %q: scheme %q is unknown, expected http or https", class, settings.Scheme))
+		}
+		if settings.ProbeAddress != "" && !validProbeAddress(settings.ProbeAddress) {
+			errs = append(errs, fmt.Errorf("ingress class %q: probe address %q is invalid, expected a host without a port", class, settings.ProbeAddress))
+		}
+	}
+
+	if c.APICheckRateLimit <= 0 {
+		errs = append(errs, fmt.Errorf("API check rate limit must be positive, got %v", c.APICheckRateLimit))
+	}
+	if c.ServerReadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server read timeout must be positive, got %v", c.ServerReadTimeout))
+	}
+	if c.ServerWriteTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server write timeout must be positive, got %v", c.ServerWriteTimeout))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS requires both a certificate and a key file"))
+	}
+	if c.ShutdownGracePeriod <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown grace period must be positive, got %v", c.ShutdownGracePeriod))
+	}
+
+	if c.LeaderElection.Enabled && c.Sharding.Enabled {
+		errs = append(errs, errors.New("leader election and sharding are mutually exclusive, enable only one of them"))
+	}
+	if c.LeaderElection.Enabled {
+		le := c.LeaderElection
+		if le.LeaseName == "" || le.LeaseNamespace == "" {
+			errs = append(errs, errors.New("leader election requires a lease name and namespace"))
+		}
+		if le.RetryPeriod <= 0 || le.RenewDeadline <= le.RetryPeriod || le.LeaseDuration <= le.RenewDeadline {
+			errs = append(errs, fmt.Errorf("leader election requires lease duration (%v) > renew deadline (%v) > retry period (%v) > 0",
+				le.LeaseDuration, le.RenewDeadline, le.RetryPeriod))
+		}
+	}
+	if c.Sharding.Enabled && (c.Sharding.PeerService == "" || c.Sharding.PeerNamespace == "") {
+		errs = append(errs, errors.New("sharding requires a peer service and namespace"))
+	}
+
+	if !c.Location.Home && len(c.Location.RemoteHostPatterns) == 0 {
+		errs = append(errs, errors.New("remote host patterns are required when not running in the home cluster"))
+	}
+	for _, pattern := range c.Location.RemoteHostPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("remote host pattern %q is invalid: %w", pattern, err))
+		}
+	}
+
+	names := make(map[string]bool, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		if len(c.Clusters) > 1 && cluster.Name == "" {
+			errs = append(errs, fmt.Errorf("cluster %d needs a name when several clusters are configured", i))
+		} else if names[cluster.Name] {
+			errs = append(errs, fmt.Errorf("cluster name %q is used more than once", cluster.Name))
+		}
+		names[cluster.Name] = true
+		if cluster.InCluster && (cluster.Kubeconfig != "" || cluster.Context != "") {
+			errs = append(errs, fmt.Errorf("cluster %q cannot use both inCluster and a kubeconfig", cluster.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validProbeAddress reports whether the address is a host without a port. The port
+// is taken from the probed URL, so that http and https requests each reach the
+// port of their scheme.
+func validProbeAddress(address string) bool {
+	if strings.Contains(address, "://") || strings.Contains(address, "/") {
+		return false
+	}
+	return !strings.Contains(address, ":") || net.ParseIP(address) != nil
+}
+
+// parseEnvDuration sets the target from a positive duration in the named variable
+func parseEnvDuration(name string, target *time.Duration) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	duration, err := parseDuration(value)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("%s: invalid value %q, expected a positive duration such as 30s or a number of seconds", name, value)
+	}
+	*target = duration
+	return nil
+}
+
+// parseEnvBool sets the target from a boolean in the named variable
+func parseEnvBool(name string, target *bool) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q, expected true or false", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+// splitEnvList splits a comma-separated variable into trimmed, non-empty items
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readConfigFile reads the explicitly named config file, or the first one found
+// in the search paths. It returns nil data when no file was named or found.
+func readConfigFile(path string) ([]byte, string, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading config file: %w", err)
+		}
+		return data, path, nil
+	}
+
+	for _, candidate := range ConfigSearchPaths {
+		data, err := os.ReadFile(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading config file: %w", err)
+		}
+		return data, candidate, nil
+	}
+	return nil, "", nil
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -15,17 +17,8 @@ func TestLoadConfigDefaults(t *testing.T) {
 	os.Unsetenv(EnvNamespaceMode)
 	os.Unsetenv(EnvNamespaces)
 
-	// Create a temporary file with a non-existent path
-	tempFile := "nonexistent_config.yaml"
-
-
-	// Set to our non-existent file
-	os.Setenv("CONFIG_FILE", tempFile)
-
-	// Restore after test
-	defer func() {
-		os.Unsetenv("CONFIG_FILE")
-	}()
+	// No config file is named or present in the search paths
+	os.Unsetenv(EnvConfigFile)
 
 	// Load the config, which should use defaults
 	cfg, err := LoadConfig()
@@ -62,11 +55,7 @@ func TestLoadConfigDefaults(t *testing.T) {
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
-	// Create a non-existent file path
-	tempFile := "nonexistent_config.yaml"
-
-	// Set to our non-existent file
-	os.Setenv("CONFIG_FILE", tempFile)
+	os.Unsetenv(EnvConfigFile)
 
 	// Set environment variables
 	os.Setenv(EnvMonitoringInterval, "60")
@@ -75,10 +64,11 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	os.Setenv(EnvSuccessStatusCodes, "401, 403, 404, 500")
 	os.Setenv(EnvNamespaceMode, "deny")
 	os.Setenv(EnvNamespaces, "default, kube-system")
+	os.Setenv(EnvVerifyRedirect, "true")
 
 	// Clean up after the test
 	defer func() {
-		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv(EnvVerifyRedirect)
 		os.Unsetenv(EnvMonitoringInterval)
 		os.Unsetenv(EnvMetricsInterval)
 		os.Unsetenv(EnvOtelCollectorURL)
@@ -119,6 +109,10 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		}
 	}
 
+	if !cfg.VerifyHTTPSRedirect {
+		t.Errorf("Expected the HTTPS redirect check to be enabled")
+	}
+
 	if cfg.NamespaceMode != "deny" {
 		t.Errorf("Expected namespace mode %s, got %s", "deny", cfg.NamespaceMode)
 	}
@@ -136,11 +130,7 @@ func TestLoadConfigFromEnv(t *testing.T) {
 }
 
 func TestLoadConfigInvalidEnv(t *testing.T) {
-	// Create a non-existent file path
-	tempFile := "nonexistent_config.yaml"
-
-	// Set to our non-existent file
-	os.Setenv("CONFIG_FILE", tempFile)
+	os.Unsetenv(EnvConfigFile)
 
 	// Set invalid environment variables
 	os.Setenv(EnvMonitoringInterval, "invalid")
@@ -150,7 +140,6 @@ func TestLoadConfigInvalidEnv(t *testing.T) {
 
 	// Clean up after the test
 	defer func() {
-		os.Unsetenv("CONFIG_FILE")
 		os.Unsetenv(EnvMonitoringInterval)
 		os.Unsetenv(EnvMetricsInterval)
 		os.Unsetenv(EnvSuccessStatusCodes)
@@ -158,28 +147,16 @@ func TestLoadConfigInvalidEnv(t *testing.T) {
 	}()
 
 	// Load the config
-	cfg, err := LoadConfig()
-
-	// Check that there was no error (should fall back to defaults)
-	if err != nil {
-		t.Fatalf("Expected no error, got: %v", err)
-	}
-
-	// Check that the defaults were used
-	if cfg.MonitoringInterval != DefaultMonitoringInterval {
-		t.Errorf("Expected monitoring interval %v, got %v", DefaultMonitoringInterval, cfg.MonitoringInterval)
-	}
-
-	if cfg.MetricsInterval != DefaultMetricsInterval {
-		t.Errorf("Expected metrics interval %v, got %v", DefaultMetricsInterval, cfg.MetricsInterval)
-	}
+	_, err := LoadConfig()
 
-	if len(cfg.SuccessStatusCodes) != len(DefaultSuccessStatusCodes) {
-		t.Errorf("Expected %d success status codes, got %d", len(DefaultSuccessStatusCodes), len(cfg.SuccessStatusCodes))
+	// Every invalid value is reported at once
+	if err == nil {
+		t.Fatalf("Expected an error for invalid environment variables, got nil")
 	}
-
-	if cfg.NamespaceMode != DefaultNamespaceMode {
-		t.Errorf("Expected namespace mode %s, got %s", DefaultNamespaceMode, cfg.NamespaceMode)
+	for _, expected := range []string{EnvMonitoringInterval, EnvMetricsInterval, EnvSuccessStatusCodes, "namespace mode"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected the error to mention %s, got: %v", expected, err)
+		}
 	}
 }
 
@@ -325,7 +302,6 @@ discovery:
 
 	// Clean up after the test
 	defer func() {
-		os.Unsetenv("CONFIG_FILE")
 		os.Unsetenv(EnvMonitoringInterval)
 		os.Unsetenv(EnvMetricsInterval)
 		os.Unsetenv(EnvOtelCollectorURL)
@@ -414,7 +390,7 @@ func TestLoadConfigInvalidFile(t *testing.T) {
 
 	// Clean up after the test
 	defer func() {
-		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv(EnvConfigFile)
 	}()
 
 	// Load the config
@@ -425,3 +401,538 @@ func TestLoadConfigInvalidFile(t *testing.T) {
 		t.Fatalf("Expected error for invalid YAML, got nil")
 	}
 }
+
+func TestLoadConfigAPIFromEnv(t *testing.T) {
+	os.Setenv(EnvAPIToken, "secret")
+	os.Setenv(EnvAPICheckRateLimit, "30")
+
+	// Clean up after the test
+	defer func() {
+		os.Unsetenv(EnvAPIToken)
+		os.Unsetenv(EnvAPICheckRateLimit)
+	}()
+
+	// Load the config
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.APIToken != "secret" {
+		t.Errorf("Expected API token %s, got %s", "secret", cfg.APIToken)
+	}
+
+	if cfg.APICheckRateLimit != 30*time.Second {
+		t.Errorf("Expected API check rate limit %v, got %v", 30*time.Second, cfg.APICheckRateLimit)
+	}
+}
+
+func TestLoadConfigServerFromEnv(t *testing.T) {
+	os.Setenv(EnvServerAddress, "127.0.0.1:9090")
+	os.Setenv(EnvServerReadTimeout, "5")
+	os.Setenv(EnvServerWriteTimeout, "15")
+	os.Setenv(EnvTLSCertFile, "/tls/tls.crt")
+	os.Setenv(EnvTLSKeyFile, "/tls/tls.key")
+	os.Setenv(EnvShutdownGrace, "45")
+
+	// Clean up after the test
+	defer func() {
+		os.Unsetenv(EnvServerAddress)
+		os.Unsetenv(EnvServerReadTimeout)
+		os.Unsetenv(EnvServerWriteTimeout)
+		os.Unsetenv(EnvTLSCertFile)
+		os.Unsetenv(EnvTLSKeyFile)
+		os.Unsetenv(EnvShutdownGrace)
+	}()
+
+	// Load the config
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.ServerAddress != "127.0.0.1:9090" {
+		t.Errorf("Expected server address %s, got %s", "127.0.0.1:9090", cfg.ServerAddress)
+	}
+	if cfg.ServerReadTimeout != 5*time.Second {
+		t.Errorf("Expected server read timeout %v, got %v", 5*time.Second, cfg.ServerReadTimeout)
+	}
+	if cfg.ServerWriteTimeout != 15*time.Second {
+		t.Errorf("Expected server write timeout %v, got %v", 15*time.Second, cfg.ServerWriteTimeout)
+	}
+	if cfg.TLSCertFile != "/tls/tls.crt" || cfg.TLSKeyFile != "/tls/tls.key" {
+		t.Errorf("Expected TLS files /tls/tls.crt and /tls/tls.key, got %s and %s", cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	if cfg.ShutdownGracePeriod != 45*time.Second {
+		t.Errorf("Expected shutdown grace period %v, got %v", 45*time.Second, cfg.ShutdownGracePeriod)
+	}
+}
+
+func TestLoadConfigLocationFromEnv(t *testing.T) {
+	os.Setenv(EnvProbeRegion, "eu-west-1")
+	os.Setenv(EnvProbeZone, "eu-west-1a")
+	os.Setenv(EnvProbeCluster, "prod-eu")
+	os.Setenv(EnvProbeHome, "false")
+	os.Setenv(EnvRemoteHostPatterns, "*.example.com, status.example.org")
+
+	// Clean up after the test
+	defer func() {
+		os.Unsetenv(EnvProbeRegion)
+		os.Unsetenv(EnvProbeZone)
+		os.Unsetenv(EnvProbeCluster)
+		os.Unsetenv(EnvProbeHome)
+		os.Unsetenv(EnvRemoteHostPatterns)
+	}()
+
+	// Load the config
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.Location.Region != "eu-west-1" || cfg.Location.Zone != "eu-west-1a" || cfg.Location.Cluster != "prod-eu" {
+		t.Errorf("Expected location eu-west-1/eu-west-1a/prod-eu, got %+v", cfg.Location)
+	}
+	if cfg.Location.Home {
+		t.Errorf("Expected home cluster to be false")
+	}
+
+	expectedPatterns := []string{"*.example.com", "status.example.org"}
+	if len(cfg.Location.RemoteHostPatterns) != len(expectedPatterns) {
+		t.Fatalf("Expected %d remote host patterns, got %v", len(expectedPatterns), cfg.Location.RemoteHostPatterns)
+	}
+	for i, pattern := range expectedPatterns {
+		if cfg.Location.RemoteHostPatterns[i] != pattern {
+			t.Errorf("Expected pattern %s at index %d, got %s", pattern, i, cfg.Location.RemoteHostPatterns[i])
+		}
+	}
+}
+
+func TestLoadConfigClustersFromFile(t *testing.T) {
+	// Save the original config file if it exists
+	if _, err := os.Stat(DefaultConfigFile); err == nil {
+		if err := os.Rename(DefaultConfigFile, DefaultConfigFile+".bak"); err != nil {
+			t.Fatalf("Failed to backup original config file: %v", err)
+		}
+		defer os.Rename(DefaultConfigFile+".bak", DefaultConfigFile)
+	}
+
+	content := `clusters:
+  - name: local
+    inCluster: true
+  - name: prod-eu
+    kubeconfig: /etc/kubeconfigs/prod
+    context: prod-eu
+`
+	if err := os.WriteFile(DefaultConfigFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create temporary config file: %v", err)
+	}
+	defer os.Remove(DefaultConfigFile)
+
+	// Load the config
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []ClusterConfig{
+		{Name: "local", InCluster: true},
+		{Name: "prod-eu", Kubeconfig: "/etc/kubeconfigs/prod", Context: "prod-eu"},
+	}
+	if len(cfg.Clusters) != len(expected) {
+		t.Fatalf("Expected %d clusters, got %v", len(expected), cfg.Clusters)
+	}
+	for i, cluster := range expected {
+		if cfg.Clusters[i] != cluster {
+			t.Errorf("Expected cluster %+v at index %d, got %+v", cluster, i, cfg.Clusters[i])
+		}
+	}
+}
+
+func TestLoadConfigFromExplicitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.yaml")
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: 90\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.MonitoringInterval != 90*time.Second {
+		t.Errorf("Expected monitoring interval %v, got %v", 90*time.Second, cfg.MonitoringInterval)
+	}
+	if cfg.File != path {
+		t.Errorf("Expected config file %s, got %s", path, cfg.File)
+	}
+
+	// CONFIG_FILE names the file when no path is passed
+	t.Setenv(EnvConfigFile, path)
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.File != path {
+		t.Errorf("Expected config file %s from %s, got %s", path, EnvConfigFile, cfg.File)
+	}
+}
+
+func TestLoadConfigMissingExplicitFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	if _, err := LoadConfigFrom(missing); err == nil {
+		t.Errorf("Expected an error for a missing config file passed explicitly")
+	}
+
+	t.Setenv(EnvConfigFile, missing)
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for a missing config file named by %s", EnvConfigFile)
+	}
+}
+
+func TestLoadConfigSearchPaths(t *testing.T) {
+	os.Unsetenv(EnvConfigFile)
+	dir := t.TempDir()
+	first := filepath.Join(dir, "etc", "config.yaml")
+	second := filepath.Join(dir, "config.yaml")
+
+	original := ConfigSearchPaths
+	ConfigSearchPaths = []string{first, second}
+	defer func() { ConfigSearchPaths = original }()
+
+	// Only the second location exists
+	if err := os.WriteFile(second, []byte("monitoring:\n  interval: 20\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.File != second || cfg.MonitoringInterval != 20*time.Second {
+		t.Errorf("Expected config from %s, got %s with interval %v", second, cfg.File, cfg.MonitoringInterval)
+	}
+
+	// The first location takes precedence once it exists
+	if err := os.MkdirAll(filepath.Dir(first), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(first, []byte("monitoring:\n  interval: 40\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.File != first || cfg.MonitoringInterval != 40*time.Second {
+		t.Errorf("Expected config from %s, got %s with interval %v", first, cfg.File, cfg.MonitoringInterval)
+	}
+}
+
+func TestLoadConfigUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `monitoring:
+  intreval: 60
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	_, err := LoadConfigFrom(path)
+	if err == nil || !strings.Contains(err.Error(), "intreval") {
+		t.Errorf("Expected an error naming the unknown field, got: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			MonitoringInterval:  DefaultMonitoringInterval,
+			CheckTimeout:        DefaultCheckTimeout,
+			MetricsInterval:     DefaultMetricsInterval,
+			SuccessStatusCodes:  DefaultSuccessStatusCodes,
+			NamespaceMode:       DefaultNamespaceMode,
+			APICheckRateLimit:   DefaultAPICheckRateLimit,
+			ServerReadTimeout:   DefaultServerReadTimeout,
+			ServerWriteTimeout:  DefaultServerWriteTimeout,
+			ShutdownGracePeriod: DefaultShutdownGrace,
+			Location:            LocationConfig{Home: true},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		expected []string
+	}{
+		{
+			name:   "valid",
+			modify: func(*Config) {},
+		},
+		{
+			name: "interval not longer than timeout",
+			modify: func(c *Config) {
+				c.MonitoringInterval = 5 * time.Second
+			},
+			expected: []string{"must be longer than the check timeout"},
+		},
+		{
+			name: "several problems",
+			modify: func(c *Config) {
+				c.SuccessStatusCodes = []int{200, 99, 600}
+				c.NamespaceMode = "block"
+				c.TLSCertFile = "/tls/tls.crt"
+			},
+			expected: []string{"99", "600", `"block"`, "certificate and a key"},
+		},
+		{
+			name: "leader election and sharding",
+			modify: func(c *Config) {
+				c.LeaderElection = LeaderElectionConfig{
+					Enabled:        true,
+					LeaseName:      DefaultLeaseName,
+					LeaseNamespace: DefaultLeaseNamespace,
+					LeaseDuration:  DefaultLeaseDuration,
+					RenewDeadline:  DefaultRenewDeadline,
+					RetryPeriod:    DefaultRetryPeriod,
+				}
+				c.Sharding = ShardingConfig{Enabled: true, PeerService: DefaultPeerService, PeerNamespace: "default"}
+			},
+			expected: []string{"mutually exclusive"},
+		},
+		{
+			name: "remote location without patterns",
+			modify: func(c *Config) {
+				c.Location.Home = false
+			},
+			expected: []string{"remote host patterns are required"},
+		},
+		{
+			name: "duplicate cluster names",
+			modify: func(c *Config) {
+				c.Clusters = []ClusterConfig{{Name: "eu", InCluster: true}, {Name: "eu", Kubeconfig: "/kube/eu"}}
+			},
+			expected: []string{`"eu" is used more than once`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected an error, got nil")
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected the error to mention %s, got: %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigSelectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `discovery:
+  ingressLabelSelector: "monitoring=enabled,tier!=internal"
+  namespaceLabelSelector: "team=payments"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	t.Setenv(EnvIngressFields, "metadata.namespace!=kube-system")
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := SelectorsConfig{
+		IngressLabels:   "monitoring=enabled,tier!=internal",
+		IngressFields:   "metadata.namespace!=kube-system",
+		NamespaceLabels: "team=payments",
+	}
+	if cfg.Selectors != expected {
+		t.Errorf("Expected selectors %+v, got %+v", expected, cfg.Selectors)
+	}
+
+	// Selectors are validated when loading
+	t.Setenv(EnvNamespaceLabels, "team in (")
+	if _, err := LoadConfigFrom(path); err == nil {
+		t.Errorf("Expected an error for an invalid namespace label selector")
+	}
+}
+
+func TestLoadConfigNamespacePatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `discovery:
+  allowNamespaces: ["*-prod"]
+  denyNamespaces: ["*-sandbox-prod", "/^legacy-/"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(cfg.AllowNamespaces) != 1 || len(cfg.DenyNamespaces) != 2 {
+		t.Errorf("Expected 1 allowed and 2 denied patterns, got %v and %v", cfg.AllowNamespaces, cfg.DenyNamespaces)
+	}
+
+	// Invalid patterns are reported when loading
+	t.Setenv(EnvDenyNamespaces, "/(/")
+	if _, err := LoadConfigFrom(path); err == nil {
+		t.Errorf("Expected an error for an invalid namespace regex")
+	}
+}
+
+func TestLoadConfigIngressClasses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `discovery:
+  ingressClasses: ["nginx"]
+  ingressClassSettings:
+    nginx:
+      scheme: https
+      probeAddress: ingress-nginx-controller.ingress-nginx.svc
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	t.Setenv(EnvIngressClasses, "nginx, traefik")
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(cfg.IngressClasses) != 2 || cfg.IngressClasses[1] != "traefik" {
+		t.Errorf("Expected ingress classes from the environment, got %v", cfg.IngressClasses)
+	}
+	expected := IngressClassConfig{Scheme: "https", ProbeAddress: "ingress-nginx-controller.ingress-nginx.svc"}
+	if cfg.IngressClassConfigs["nginx"] != expected {
+		t.Errorf("Expected nginx settings %+v, got %+v", expected, cfg.IngressClassConfigs["nginx"])
+	}
+
+	// Class settings are validated when loading
+	cfg.IngressClassConfigs["traefik"] = IngressClassConfig{Scheme: "ftp", ProbeAddress: "http://traefik"}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "scheme") || !strings.Contains(err.Error(), "probe address") {
+		t.Errorf("Expected errors for the scheme and probe address, got %v", err)
+	}
+}
+
+func TestLoadConfigProbeMode(t *testing.T) {
+	os.Unsetenv(EnvConfigFile)
+	t.Setenv(EnvProbeMode, "Controller")
+
+	// The controller mode needs an address
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "controller address") {
+		t.Errorf("Expected an error for a missing controller address, got %v", err)
+	}
+
+	// The port is taken from the probed URL, so one in the address is rejected
+	t.Setenv(EnvControllerAddress, "ingress-nginx-controller.ingress-nginx.svc:443")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "without a port") {
+		t.Errorf("Expected an error for a controller address with a port, got %v", err)
+	}
+
+	t.Setenv(EnvControllerAddress, "ingress-nginx-controller.ingress-nginx.svc")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.ProbeMode != "controller" || cfg.ControllerAddress != "ingress-nginx-controller.ingress-nginx.svc" {
+		t.Errorf("Expected the controller mode and address, got %q and %q", cfg.ProbeMode, cfg.ControllerAddress)
+	}
+
+	t.Setenv(EnvProbeMode, "direct")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an unknown probe mode")
+	}
+}
+
+func TestLoadConfigBackendProbing(t *testing.T) {
+	os.Unsetenv(EnvConfigFile)
+	t.Setenv(EnvProbeBackends, "true")
+	t.Setenv(EnvBackendResolution, "EndpointSlices")
+	t.Setenv(EnvProbePods, "true")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !cfg.ProbeBackends || cfg.BackendResolution != "endpointslices" {
+		t.Errorf("Expected backend probing through EndpointSlices, got %v and %q", cfg.ProbeBackends, cfg.BackendResolution)
+	}
+	if !cfg.ProbePods {
+		t.Errorf("Expected pod probing to be enabled")
+	}
+
+	t.Setenv(EnvBackendResolution, "dns")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an unknown backend resolution")
+	}
+}
+
+func TestLoadConfigServiceDiscovery(t *testing.T) {
+	os.Unsetenv(EnvConfigFile)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.ServiceDiscovery || cfg.ClusterDomain != DefaultClusterDomain {
+		t.Errorf("Expected Service discovery disabled in %q, got %v in %q", DefaultClusterDomain, cfg.ServiceDiscovery, cfg.ClusterDomain)
+	}
+
+	t.Setenv(EnvServiceDiscovery, "true")
+	t.Setenv(EnvClusterDomain, "corp.internal.")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !cfg.ServiceDiscovery || cfg.ClusterDomain != "corp.internal" {
+		t.Errorf("Expected Service discovery in %q, got %v in %q", "corp.internal", cfg.ServiceDiscovery, cfg.ClusterDomain)
+	}
+
+	t.Setenv(EnvClusterDomain, "http://cluster.local")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an invalid cluster domain")
+	}
+}
+
+func TestLoadConfigSources(t *testing.T) {
+	os.Unsetenv(EnvConfigFile)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !cfg.Sources.Routes {
+		t.Errorf("Expected Route discovery to be enabled by default")
+	}
+	if cfg.Sources.VirtualServices || cfg.Sources.IngressRoutes {
+		t.Errorf("Expected VirtualService and IngressRoute discovery to be disabled by default")
+	}
+
+	t.Setenv(EnvRouteDiscovery, "false")
+	t.Setenv(EnvVirtualServices, "true")
+	t.Setenv(EnvIngressRoutes, "true")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Sources.Routes {
+		t.Errorf("Expected Route discovery to be disabled")
+	}
+	if !cfg.Sources.VirtualServices || !cfg.Sources.IngressRoutes {
+		t.Errorf("Expected VirtualService and IngressRoute discovery to be enabled")
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a duration in the config file, written either as a Go duration
+// string such as "500ms" or "2m", or as an integer number of seconds
+type Duration time.Duration
+
+// UnmarshalYAML decodes a duration string or a number of seconds
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a duration such as 30s or a number of seconds", node.Line)
+	}
+	duration, err := parseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// parseDuration parses a Go duration string, or a bare integer as seconds for
+// backwards compatibility
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a duration such as 30s or a number of seconds", value)
+	}
+	return duration, nil
+}
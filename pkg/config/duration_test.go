@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"30", 30 * time.Second, false},
+		{" 45 ", 45 * time.Second, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"2m", 2 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"thirty", 0, true},
+		{"1.5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			duration, err := parseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if duration != tt.expected {
+				t.Errorf("parseDuration(%q) = %v, expected %v", tt.value, duration, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDurations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `monitoring:
+  interval: 2m
+  timeout: 1500ms
+metrics:
+  interval: 15
+server:
+  shutdownGracePeriod: "45s"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.MonitoringInterval != 2*time.Minute {
+		t.Errorf("Expected monitoring interval %v, got %v", 2*time.Minute, cfg.MonitoringInterval)
+	}
+	if cfg.CheckTimeout != 1500*time.Millisecond {
+		t.Errorf("Expected check timeout %v, got %v", 1500*time.Millisecond, cfg.CheckTimeout)
+	}
+	if cfg.MetricsInterval != 15*time.Second {
+		t.Errorf("Expected metrics interval %v, got %v", 15*time.Second, cfg.MetricsInterval)
+	}
+	if cfg.ShutdownGracePeriod != 45*time.Second {
+		t.Errorf("Expected shutdown grace period %v, got %v", 45*time.Second, cfg.ShutdownGracePeriod)
+	}
+
+	// Environment variables accept durations as well
+	t.Setenv(EnvMonitoringInterval, "90s")
+	t.Setenv(EnvMonitorTimeout, "5")
+	cfg, err = LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.MonitoringInterval != 90*time.Second {
+		t.Errorf("Expected monitoring interval %v, got %v", 90*time.Second, cfg.MonitoringInterval)
+	}
+	if cfg.CheckTimeout != 5*time.Second {
+		t.Errorf("Expected check timeout %v, got %v", 5*time.Second, cfg.CheckTimeout)
+	}
+}
+
+func TestLoadConfigInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: soon\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	if _, err := LoadConfigFrom(path); err == nil {
+		t.Errorf("Expected an error for an invalid duration")
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// DefaultReloadInterval is how often the config file is checked for changes
+const DefaultReloadInterval = 10 * time.Second
+
+// Reloader reloads the configuration when its file changes or when asked to
+type Reloader struct {
+	path        string
+	interval    time.Duration
+	onReload    func(*Config, error)
+	requests    chan struct{}
+	fingerprint string
+}
+
+// NewReloader creates a reloader for the configuration file at path, which is
+// resolved like in LoadConfigFrom. onReload receives either the new, validated
+// configuration or the error it was rejected with.
+func NewReloader(path string, interval time.Duration, onReload func(*Config, error)) *Reloader {
+	r := &Reloader{
+		path:     path,
+		interval: interval,
+		onReload: onReload,
+		requests: make(chan struct{}, 1),
+	}
+	r.fingerprint = r.currentFingerprint()
+	return r
+}
+
+// Reload requests a reload even if the file did not change, e.g. on SIGHUP
+func (r *Reloader) Reload() {
+	select {
+	case r.requests <- struct{}{}:
+	default:
+	}
+}
+
+// Run watches the config file until the context is cancelled
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.requests:
+			r.fingerprint = r.currentFingerprint()
+			r.reload()
+		case <-ticker.C:
+			if fingerprint := r.currentFingerprint(); fingerprint != r.fingerprint {
+				r.fingerprint = fingerprint
+				r.reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) reload() {
+	config, err := LoadConfigFrom(r.path)
+	r.onReload(config, err)
+}
+
+// currentFingerprint identifies the content of the config file. The content is
+// hashed rather than relying on modification times because ConfigMap volumes
+// are updated by swapping a symlink to a new directory.
+func (r *Reloader) currentFingerprint() string {
+	data, path, err := readConfigFile(r.path)
+	if err != nil {
+		return "error:" + err.Error()
+	}
+	sum := sha256.Sum256(data)
+	return path + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type reloadResult struct {
+	config *Config
+	err    error
+}
+
+func startReloader(t *testing.T, path string) (*Reloader, <-chan reloadResult) {
+	t.Helper()
+	results := make(chan reloadResult, 10)
+	r := NewReloader(path, 10*time.Millisecond, func(config *Config, err error) {
+		results <- reloadResult{config, err}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go r.Run(ctx)
+	return r, results
+}
+
+func waitForReload(t *testing.T, results <-chan reloadResult) reloadResult {
+	t.Helper()
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a reload")
+		return reloadResult{}
+	}
+}
+
+func TestReloaderFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: 30\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	_, results := startReloader(t, path)
+
+	// A valid change is loaded
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: 60\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	result := waitForReload(t, results)
+	if result.err != nil {
+		t.Fatalf("Expected no error, got: %v", result.err)
+	}
+	if result.config.MonitoringInterval != 60*time.Second {
+		t.Errorf("Expected monitoring interval %v, got %v", 60*time.Second, result.config.MonitoringInterval)
+	}
+
+	// An invalid change is reported
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: 5\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if result := waitForReload(t, results); result.err == nil {
+		t.Errorf("Expected an error for an interval shorter than the check timeout")
+	}
+}
+
+func TestReloaderSymlinkSwap(t *testing.T) {
+	// Mimic a ConfigMap volume: config.yaml -> ..data/config.yaml, ..data -> version dir
+	dir := t.TempDir()
+	for version, interval := range map[string]string{"v1": "30", "v2": "45"} {
+		if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+			t.Fatalf("Failed to create version dir: %v", err)
+		}
+		content := "monitoring:\n  interval: " + interval + "\n"
+		if err := os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), path); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	_, results := startReloader(t, path)
+
+	// Atomically repoint ..data like the kubelet does
+	if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Failed to swap symlink: %v", err)
+	}
+
+	result := waitForReload(t, results)
+	if result.err != nil {
+		t.Fatalf("Expected no error, got: %v", result.err)
+	}
+	if result.config.MonitoringInterval != 45*time.Second {
+		t.Errorf("Expected monitoring interval %v, got %v", 45*time.Second, result.config.MonitoringInterval)
+	}
+}
+
+func TestReloaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("monitoring:\n  interval: 30\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	r, results := startReloader(t, path)
+
+	// An explicit request reloads an unchanged file
+	r.Reload()
+	result := waitForReload(t, results)
+	if result.err != nil {
+		t.Fatalf("Expected no error, got: %v", result.err)
+	}
+	if result.config.MonitoringInterval != 30*time.Second {
+		t.Errorf("Expected monitoring interval %v, got %v", 30*time.Second, result.config.MonitoringInterval)
+	}
+}
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+)
+
+// BackendResolution selects how the address of a backend Service is resolved
+type BackendResolution string
+
+const (
+	// BackendResolutionClusterIP uses the ClusterIP of the Service, and the
+	// EndpointSlices of headless Services
+	BackendResolutionClusterIP BackendResolution = "clusterip"
+	// BackendResolutionEndpointSlices uses a ready endpoint from the EndpointSlices
+	BackendResolutionEndpointSlices BackendResolution = "endpointslices"
+)
+
+// ParseBackendResolution parses a backend resolution, case-insensitively. An empty
+// resolution is BackendResolutionClusterIP.
+func ParseBackendResolution(resolution string) (BackendResolution, error) {
+	switch parsed := BackendResolution(strings.ToLower(strings.TrimSpace(resolution))); parsed {
+	case "":
+		return BackendResolutionClusterIP, nil
+	case BackendResolutionClusterIP, BackendResolutionEndpointSlices:
+		return parsed, nil
+	default:
+		return "", fmt.Errorf("backend resolution %q is unknown, expected clusterip or endpointslices", resolution)
+	}
+}
+
+// BackendOptions enables probing the backend Services of Ingresses directly
+type BackendOptions struct {
+	Enabled    bool // Probe the backend Service of every endpoint
+	Resolution BackendResolution
+	Pods       bool // Probe every pod of the backend Service of annotated Ingresses
+}
+
+// AnnotationProbePods opts an Ingress into probing every pod of its backend
+// Services ("true"), when pod probing is enabled
+const AnnotationProbePods = "health.monitor/probe-pods"
+
+// BackendPod is a pod backing a Service, from its EndpointSlices
+type BackendPod struct {
+	Name        string // Pod name, or the address when the endpoint is not a pod
+	URL         string // Base URL such as http://10.244.1.6:8080
+	Ready       bool
+	Terminating bool
+}
+
+// backendListers holds the caches used to resolve backend Services
+type backendListers struct {
+	services      bool // Whether backend Services are probed
+	pods          bool // Whether pods of annotated Ingresses are probed
+	resolution    BackendResolution
+	serviceLister corelisters.ServiceLister
+	sliceLister   discoverylisters.EndpointSliceLister
+}
+
+// watchBackends adds an EndpointSlice informer to the client, and the shared
+// Service informer
+func (c *Client) watchBackends(opts BackendOptions) {
+	factory := informers.NewSharedInformerFactory(c.clientset, 0)
+	sliceInformer := factory.Discovery().V1().EndpointSlices()
+
+	c.backends = &backendListers{
+		services:      opts.Enabled,
+		pods:          opts.Pods,
+		resolution:    opts.Resolution,
+		serviceLister: c.watchServiceObjects().Lister(),
+		sliceLister:   sliceInformer.Lister(),
+	}
+	c.cacheSyncs = append(c.cacheSyncs, sliceInformer.Informer().HasSynced)
+	c.factories = append(c.factories, factory)
+}
+
+// ProbesBackend reports whether the backend Service of the endpoint is probed
+func (c *Client) ProbesBackend(endpoint Endpoint) bool {
+	return c.backends != nil && c.backends.services && routed(endpoint)
+}
+
+// ProbesPods reports whether the pods backing the endpoint are probed, which its
+// Ingress opts into with an annotation
+func (c *Client) ProbesPods(endpoint Endpoint) bool {
+	return c.backends != nil && c.backends.pods && routed(endpoint) &&
+		endpoint.Annotations[AnnotationProbePods] == "true"
+}
+
+// routed reports whether the endpoint reaches its backend Service through a router.
+// Annotated Services are already probed directly, so probing them again as their
+// own backend would not tell a routing failure apart.
+func routed(endpoint Endpoint) bool {
+	return endpoint.ServiceName != "" && endpoint.Source != SourceService
+}
+
+// ResolveBackend returns the base URL, such as http://10.0.12.7:8080, at which
+// the backend Service of the endpoint can be probed directly
+func (c *Client) ResolveBackend(endpoint Endpoint) (string, error) {
+	if c.backends == nil || !c.backends.services {
+		return "", errors.New("backend probing is not enabled")
+	}
+	service, port, err := c.backendService(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	headless := service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone
+	if c.backends.resolution == BackendResolutionClusterIP && !headless {
+		return backendScheme(port) + "://" + net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(int(port.Port))), nil
+	}
+
+	pods, err := c.backendPods(service, port)
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods {
+		if pod.Ready {
+			return pod.URL, nil
+		}
+	}
+	return "", fmt.Errorf("service %s/%s has no ready endpoints", service.Namespace, service.Name)
+}
+
+// ResolvePods returns every pod backing the backend Service of the endpoint, ready or not
+func (c *Client) ResolvePods(endpoint Endpoint) ([]BackendPod, error) {
+	if c.backends == nil || !c.backends.pods {
+		return nil, errors.New("pod probing is not enabled")
+	}
+	service, port, err := c.backendService(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return c.backendPods(service, port)
+}
+
+// backendService returns the backend Service of the endpoint and the referenced port
+func (c *Client) backendService(endpoint Endpoint) (*corev1.Service, corev1.ServicePort, error) {
+	if endpoint.ServiceName == "" {
+		return nil, corev1.ServicePort{}, errors.New("endpoint has no backend service")
+	}
+
+	service, err := c.backends.serviceLister.Services(endpoint.Namespace).Get(endpoint.ServiceName)
+	if err != nil {
+		return nil, corev1.ServicePort{}, fmt.Errorf("service %s/%s: %w", endpoint.Namespace, endpoint.ServiceName, err)
+	}
+	port, ok := servicePort(service, endpoint)
+	if !ok {
+		return nil, corev1.ServicePort{}, fmt.Errorf("service %s/%s has no port %s", endpoint.Namespace, endpoint.ServiceName, backendPortName(endpoint))
+	}
+	return service, port, nil
+}
+
+// backendPods lists the endpoints of the Service port from its EndpointSlices
+func (c *Client) backendPods(service *corev1.Service, port corev1.ServicePort) ([]BackendPod, error) {
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name})
+	slices, err := c.backends.sliceLister.EndpointSlices(service.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := backendScheme(port)
+	var pods []BackendPod
+	for _, slice := range slices {
+		// EndpointSlice ports are named after the Service port
+		var targetPort *int32
+		for _, slicePort := range slice.Ports {
+			if slicePort.Port != nil && (slicePort.Name == nil || *slicePort.Name == port.Name) {
+				targetPort = slicePort.Port
+				break
+			}
+		}
+		if targetPort == nil {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if len(endpoint.Addresses) == 0 {
+				continue
+			}
+			pod := BackendPod{
+				Name:        endpoint.Addresses[0],
+				URL:         scheme + "://" + net.JoinHostPort(endpoint.Addresses[0], strconv.Itoa(int(*targetPort))),
+				Ready:       endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready,
+				Terminating: endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating,
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				pod.Name = endpoint.TargetRef.Name
+			}
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// servicePort returns the Service port referenced by the endpoint by number or name,
+// or by the number of the pod port it forwards to
+func servicePort(service *corev1.Service, endpoint Endpoint) (corev1.ServicePort, bool) {
+	for _, port := range service.Spec.Ports {
+		switch {
+		case endpoint.ServicePortName != "":
+			if port.Name == endpoint.ServicePortName {
+				return port, true
+			}
+		case endpoint.TargetPort != 0:
+			// An unset target port is the same as the Service port
+			if port.TargetPort.IntValue() == int(endpoint.TargetPort) ||
+				(port.TargetPort.IntVal == 0 && port.TargetPort.StrVal == "" && port.Port == endpoint.TargetPort) {
+				return port, true
+			}
+		case port.Port == endpoint.ServicePort:
+			return port, true
+		}
+	}
+	return corev1.ServicePort{}, false
+}
+
+// backendPortName describes the Service port referenced by the endpoint
+func backendPortName(endpoint Endpoint) string {
+	switch {
+	case endpoint.ServicePortName != "":
+		return strconv.Quote(endpoint.ServicePortName)
+	case endpoint.TargetPort != 0:
+		return "with target port " + strconv.Itoa(int(endpoint.TargetPort))
+	default:
+		return strconv.Itoa(int(endpoint.ServicePort))
+	}
+}
+
+// backendScheme guesses the scheme served on a Service port
+func backendScheme(port corev1.ServicePort) string {
+	if port.Port == 443 || port.Name == "https" || (port.AppProtocol != nil && *port.AppProtocol == "https") {
+		return "https"
+	}
+	return "http"
+}
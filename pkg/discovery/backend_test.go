@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// backendTestObjects returns a ClusterIP Service, and a headless Service with an
+// EndpointSlice holding a pod that is not ready and one that is
+func backendTestObjects() []runtime.Object {
+	ready, notReady := true, false
+	httpPort, metricsPort := int32(8080), int32(9090)
+	httpName, metricsName := "http", "metrics"
+
+	return []runtime.Object{
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.96.0.10",
+				Ports: []corev1.ServicePort{
+					{Name: "http", Port: 80, TargetPort: intstr.FromInt32(8080)},
+					{Name: "https", Port: 8443},
+				},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: corev1.ClusterIPNone,
+				Ports:     []corev1.ServicePort{{Name: "http", Port: 80}, {Name: "metrics", Port: 9090}},
+			},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "headless-abc",
+				Namespace: "default",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "headless"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports: []discoveryv1.EndpointPort{
+				{Name: &metricsName, Port: &metricsPort},
+				{Name: &httpName, Port: &httpPort},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{
+					Addresses:  []string{"10.244.1.5"},
+					Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+					TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: "headless-5d8f-a"},
+				},
+				{
+					Addresses:  []string{"10.244.1.6"},
+					Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+					TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: "headless-5d8f-b"},
+				},
+			},
+		},
+	}
+}
+
+func TestResolveBackend(t *testing.T) {
+	objects := backendTestObjects()
+
+	tests := []struct {
+		name       string
+		resolution BackendResolution
+		endpoint   Endpoint
+		expected   string
+		wantErr    bool
+	}{
+		{"cluster IP by number", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", ServicePort: 80}, "http://10.96.0.10:80", false},
+		{"cluster IP by name", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", ServicePortName: "https"}, "https://10.96.0.10:8443", false},
+		{"headless", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "headless", ServicePort: 80}, "http://10.244.1.6:8080", false},
+		{"endpoint slices", BackendResolutionEndpointSlices, Endpoint{Namespace: "default", ServiceName: "headless", ServicePortName: "metrics"}, "http://10.244.1.6:9090", false},
+		{"cluster IP by target port", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", TargetPort: 8080}, "http://10.96.0.10:80", false},
+		{"cluster IP by default target port", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", TargetPort: 8443}, "https://10.96.0.10:8443", false},
+		{"unknown target port", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", TargetPort: 80}, "", true},
+		{"unknown port", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "web", ServicePort: 81}, "", true},
+		{"unknown service", BackendResolutionClusterIP, Endpoint{Namespace: "default", ServiceName: "missing", ServicePort: 80}, "", true},
+		{"no ready endpoints", BackendResolutionEndpointSlices, Endpoint{Namespace: "default", ServiceName: "web", ServicePort: 80}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClientForClientset(fake.NewClientset(objects...), ClusterOptions{
+				Backends: BackendOptions{Enabled: true, Resolution: tt.resolution},
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client.Start(ctx)
+			if !client.WaitForSync(ctx) {
+				t.Fatal("Caches did not sync")
+			}
+
+			base, err := client.ResolveBackend(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if base != tt.expected {
+				t.Errorf("ResolveBackend() = %q, expected %q", base, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveBackendDisabled(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(), ClusterOptions{})
+	if client.ProbesBackend(Endpoint{Namespace: "default", ServiceName: "web"}) {
+		t.Error("Expected backends not to be probed by default")
+	}
+	if _, err := client.ResolveBackend(Endpoint{Namespace: "default", ServiceName: "web"}); err == nil {
+		t.Error("Expected an error when backend probing is disabled")
+	}
+}
+
+func TestProbesBackend(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(), ClusterOptions{
+		Backends: BackendOptions{Enabled: true},
+	})
+
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		expected bool
+	}{
+		{"ingress", Endpoint{Source: SourceIngress, Namespace: "default", ServiceName: "web"}, true},
+		{"route", Endpoint{Source: SourceRoute, Namespace: "default", ServiceName: "web"}, true},
+		{"annotated service", Endpoint{Source: SourceService, Namespace: "default", ServiceName: "web"}, false},
+		{"no backend service", Endpoint{Source: SourceIngress, Namespace: "default"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if probed := client.ProbesBackend(tt.endpoint); probed != tt.expected {
+				t.Errorf("ProbesBackend() = %v, expected %v", probed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolvePods(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(backendTestObjects()...), ClusterOptions{
+		Backends: BackendOptions{Pods: true},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	endpoint := Endpoint{Namespace: "default", ServiceName: "headless", ServicePort: 80}
+	if client.ProbesBackend(endpoint) || client.ProbesPods(endpoint) {
+		t.Error("Expected only annotated endpoints to have their pods probed")
+	}
+	endpoint.Annotations = map[string]string{AnnotationProbePods: "true"}
+	if !client.ProbesPods(endpoint) {
+		t.Error("Expected the pods of the annotated endpoint to be probed")
+	}
+	service := endpoint
+	service.Source = SourceService
+	if client.ProbesPods(service) {
+		t.Error("Expected the pods of an annotated Service not to be probed")
+	}
+
+	pods, err := client.ResolvePods(endpoint)
+	if err != nil {
+		t.Fatalf("ResolvePods() error = %v", err)
+	}
+	expected := []BackendPod{
+		{Name: "headless-5d8f-a", URL: "http://10.244.1.5:8080", Ready: false},
+		{Name: "headless-5d8f-b", URL: "http://10.244.1.6:8080", Ready: true},
+	}
+	if len(pods) != len(expected) {
+		t.Fatalf("Expected %d pods, got %+v", len(expected), pods)
+	}
+	for i := range expected {
+		if pods[i] != expected[i] {
+			t.Errorf("Expected pod %+v, got %+v", expected[i], pods[i])
+		}
+	}
+}
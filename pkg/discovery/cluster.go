@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterOptions selects the Kubernetes cluster a client discovers endpoints in.
+// When none of InCluster, Kubeconfig, Context and MasterURL is set and KUBECONFIG
+// is unset, the in-cluster config is tried first with a fallback to the default
+// kubeconfig.
+type ClusterOptions struct {
+	Name       string // Added as the cluster attribute of every endpoint
+	Kubeconfig string // Path to a kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config
+	Context    string // Kubeconfig context, defaults to the current context
+	MasterURL  string // Overrides the API server address from the kubeconfig
+	InCluster  bool   // Use the pod's service account instead of a kubeconfig
+	Selectors  Selectors
+	Classes    IngressClasses
+	Probe      ProbeOptions
+	Backends   BackendOptions
+	Services   ServiceOptions
+	Sources    ResourceSources
+}
+
+// NewClientForCluster creates a client for the cluster described by the options
+func NewClientForCluster(opts ClusterOptions) (*Client, error) {
+	if err := opts.Selectors.Validate(); err != nil {
+		return nil, fmt.Errorf("cluster %q: %w", opts.Name, err)
+	}
+
+	config, err := restConfigForCluster(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q: %w", opts.Name, err)
+	}
+
+	// Create the clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q: %w", opts.Name, err)
+	}
+
+	// Routing resources such as Routes are custom resources
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q: %w", opts.Name, err)
+	}
+
+	c := NewClientForClientset(clientset, opts)
+	c.dynamicClient = dynamicClient
+	return c, nil
+}
+
+// restConfigForCluster builds the REST config for in-cluster or kubeconfig access.
+// Kubeconfig files are located with clientcmd's standard loading rules, so a
+// KUBECONFIG list of files is merged the same way kubectl does.
+func restConfigForCluster(opts ClusterOptions) (*rest.Config, error) {
+	if opts.InCluster {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("in-cluster config: %w", err)
+		}
+		return config, nil
+	}
+
+	// Try in-cluster config first when nothing specific was requested
+	var inClusterErr error
+	if opts.Kubeconfig == "" && opts.Context == "" && opts.MasterURL == "" &&
+		os.Getenv(clientcmd.RecommendedConfigPathEnvVar) == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
+		}
+		inClusterErr = err
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+	overrides.ClusterInfo.Server = opts.MasterURL
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err == nil {
+		return config, nil
+	}
+
+	sources := opts.Kubeconfig
+	if sources == "" {
+		sources = strings.Join(loadingRules.GetLoadingPrecedence(), string(filepath.ListSeparator))
+	}
+	if clientcmd.IsEmptyConfig(err) {
+		if inClusterErr != nil {
+			return nil, fmt.Errorf("no Kubernetes configuration found: not running in a cluster (%v) and no usable kubeconfig in %s; set KUBECONFIG or --kubeconfig", inClusterErr, sources)
+		}
+		return nil, fmt.Errorf("no usable kubeconfig in %s", sources)
+	}
+	return nil, fmt.Errorf("loading kubeconfig from %s: %w", sources, err)
+}
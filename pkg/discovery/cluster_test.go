@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+  - name: eu
+    cluster:
+      server: https://eu.example.com:6443
+  - name: us
+    cluster:
+      server: https://us.example.com:6443
+users:
+  - name: monitor
+    user:
+      token: secret
+contexts:
+  - name: eu
+    context:
+      cluster: eu
+      user: monitor
+  - name: us
+    context:
+      cluster: us
+      user: monitor
+current-context: eu
+`
+
+func TestRestConfigForCluster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		opts           ClusterOptions
+		expectedServer string
+	}{
+		{
+			name:           "current context",
+			opts:           ClusterOptions{Name: "eu", Kubeconfig: path},
+			expectedServer: "https://eu.example.com:6443",
+		},
+		{
+			name:           "explicit context",
+			opts:           ClusterOptions{Name: "us", Kubeconfig: path, Context: "us"},
+			expectedServer: "https://us.example.com:6443",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := restConfigForCluster(tt.opts)
+			if err != nil {
+				t.Fatalf("restConfigForCluster() error = %v", err)
+			}
+			if config.Host != tt.expectedServer {
+				t.Errorf("Expected server %s, got %s", tt.expectedServer, config.Host)
+			}
+		})
+	}
+}
+
+func TestNewClientForClusterUnknownContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	if _, err := NewClientForCluster(ClusterOptions{Name: "missing", Kubeconfig: path, Context: "missing"}); err == nil {
+		t.Errorf("Expected an error for an unknown context")
+	}
+}
+
+func TestNewClientForClusterName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	client, err := NewClientForCluster(ClusterOptions{Name: "us", Kubeconfig: path, Context: "us"})
+	if err != nil {
+		t.Fatalf("NewClientForCluster() error = %v", err)
+	}
+	if client.Cluster() != "us" {
+		t.Errorf("Expected cluster %q, got %q", "us", client.Cluster())
+	}
+}
+
+func TestRestConfigForClusterKubeconfigEnv(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	// The first file only selects the context, the second defines it
+	if err := os.WriteFile(first, []byte("apiVersion: v1\nkind: Config\ncurrent-context: us\n"), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(second, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	t.Setenv("KUBECONFIG", first+string(filepath.ListSeparator)+second)
+
+	config, err := restConfigForCluster(ClusterOptions{Name: "us"})
+	if err != nil {
+		t.Fatalf("restConfigForCluster() error = %v", err)
+	}
+	if config.Host != "https://us.example.com:6443" {
+		t.Errorf("Expected the merged KUBECONFIG to select us, got %s", config.Host)
+	}
+
+	config, err = restConfigForCluster(ClusterOptions{Name: "us", MasterURL: "https://override.example.com"})
+	if err != nil {
+		t.Fatalf("restConfigForCluster() error = %v", err)
+	}
+	if config.Host != "https://override.example.com" {
+		t.Errorf("Expected the master URL to override the server, got %s", config.Host)
+	}
+}
+
+func TestRestConfigForClusterNoConfig(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := restConfigForCluster(ClusterOptions{})
+	if err == nil {
+		t.Fatal("Expected an error when no configuration is available")
+	}
+	if !strings.Contains(err.Error(), "no usable kubeconfig") {
+		t.Errorf("Expected a descriptive error, got %v", err)
+	}
+}
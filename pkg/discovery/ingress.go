@@ -2,184 +2,736 @@ package discovery
 
 import (
 	"context"
-	"log"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
 )
 
+// ErrNotSynced is returned when endpoints are requested before the Ingress cache has synced
+var ErrNotSynced = errors.New("ingress cache has not synced yet")
+
 // Client handles Kubernetes API calls
 type Client struct {
-	clientset *kubernetes.Clientset
-	namespaceMode string // "allow" or "deny"
-	namespaces []string
+	cluster         string
+	clientset       kubernetes.Interface
+	dynamicClient   dynamic.Interface // Watches the resource sources, when set
+	ingressLister   networkinglisters.IngressLister
+	namespaceLister corelisters.NamespaceLister // Set when a namespace label selector is used
+	classLister     networkinglisters.IngressClassLister
+	classes         IngressClasses
+	probe           ProbeOptions
+	backends        *backendListers               // Set when backend Services are probed
+	resources       []*resourceSource             // Routing resources other than Ingresses
+	serviceInformer coreinformers.ServiceInformer // Shared by backend probing and Service discovery
+	serviceLister   corelisters.ServiceLister     // Set when annotated Services are discovered
+	clusterDomain   string
+	cacheSyncs      []cache.InformerSynced
+	factories       []informers.SharedInformerFactory
+	filterMu        sync.RWMutex // Protects the namespace filter, which can be reloaded
+	namespaceMode   string       // "allow" or "deny"
+	namespaces      []string
+	namespaceList   *namespaceMatcher // Compiled from namespaces
+	allowList       *namespaceMatcher // Namespaces to discover, all when empty
+	denyList        *namespaceMatcher // Namespaces to skip even when allowed
+	skippedMu       sync.RWMutex      // Protects skipped
+	skipped         []SkippedRule     // Rules skipped by the last discovery
 }
 
 // Endpoint represents a discovered endpoint
 type Endpoint struct {
-	Namespace   string
-	ServiceName string
-	IngressName string
-	URL         string
-	Path        string
-	Labels      map[string]string
-	Annotations map[string]string
+	Cluster         string
+	Source          Source // Kind of object the endpoint was discovered from
+	Namespace       string
+	ServiceName     string
+	ServicePort     int32  // Port number of the backend Service, when referenced by number
+	ServicePortName string // Port name of the backend Service, when referenced by name
+	TargetPort      int32  // Pod port of the backend Service, when referenced by it as by Routes
+	IngressName     string // Name of the Ingress, or of the Route or other routing resource
+	IngressClass    string
+	URL             string
+	Path            string
+	ProbeAddress    string // When set, connect to this host on the port of the URL instead of resolving the URL host
+	ProbeMode       ProbeMode
+	Labels          map[string]string
+	Annotations     map[string]string
+}
+
+// Source is the kind of object an endpoint is discovered from
+type Source string
+
+const (
+	// SourceIngress endpoints are the rules of Ingresses
+	SourceIngress Source = "ingress"
+	// SourceService endpoints are annotated Services, probed without an Ingress
+	SourceService Source = "service"
+)
+
+// ProbeMode describes how the address an endpoint is probed at is determined
+type ProbeMode string
+
+const (
+	// ProbeModeDNS resolves the host of the endpoint URL
+	ProbeModeDNS ProbeMode = "dns"
+	// ProbeModeLoadBalancer connects to the load balancer address in the Ingress status
+	ProbeModeLoadBalancer ProbeMode = "loadbalancer"
+	// ProbeModeController connects to the address of the ingress controller
+	ProbeModeController ProbeMode = "controller"
+)
+
+// ParseProbeMode parses a probe mode, case-insensitively. An empty mode is ProbeModeDNS.
+func ParseProbeMode(mode string) (ProbeMode, error) {
+	switch parsed := ProbeMode(strings.ToLower(strings.TrimSpace(mode))); parsed {
+	case "":
+		return ProbeModeDNS, nil
+	case ProbeModeDNS, ProbeModeLoadBalancer, ProbeModeController:
+		return parsed, nil
+	default:
+		return "", fmt.Errorf("probe mode %q is unknown, expected dns, loadbalancer or controller", mode)
+	}
+}
+
+// ProbeOptions selects the address endpoints are probed at. The Host header and
+// TLS server name are always those of the endpoint URL.
+type ProbeOptions struct {
+	Mode              ProbeMode
+	ControllerAddress string // Host of the ingress controller, for ProbeModeController
+}
+
+// Legacy annotation selecting the class of an Ingress
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+const (
+	// AnnotationScheme forces the scheme ("http" or "https") used to probe an Ingress
+	AnnotationScheme = "health.monitor/scheme"
+	// AnnotationVerifyHTTPSRedirect enables or disables ("true" or "false") checking
+	// that the http URL of an https endpoint redirects to https
+	AnnotationVerifyHTTPSRedirect = "health.monitor/verify-https-redirect"
+	// AnnotationWildcardHost lists the concrete hosts probed for wildcard rules,
+	// e.g. "www.example.com" for a rule on *.example.com
+	AnnotationWildcardHost = "health.monitor/wildcard-host"
+	// AnnotationHostHeader sets the Host header sent when probing host-less rules and
+	// the default backend through the load balancer address
+	AnnotationHostHeader = "health.monitor/host-header"
+)
+
+// IngressClasses selects Ingresses by class and configures how each class is probed
+type IngressClasses struct {
+	Include  []string                        // Only discover Ingresses of these classes, all when empty
+	Settings map[string]IngressClassSettings // Per-class probe settings
+}
+
+// IngressClassSettings overrides how the endpoints of an IngressClass are probed
+type IngressClassSettings struct {
+	Scheme       string // "http" or "https", instead of deriving it from the TLS section
+	ProbeAddress string // Host to connect to, e.g. the controller's internal address
+}
+
+// includes reports whether Ingresses of the class are discovered
+func (ic IngressClasses) includes(class string) bool {
+	if len(ic.Include) == 0 {
+		return true
+	}
+	for _, included := range ic.Include {
+		if included == class {
+			return true
+		}
+	}
+	return false
 }
 
-// SetNamespaceFilter sets the namespace filtering mode and list
-func (c *Client) SetNamespaceFilter(mode string, namespaces []string) {
+// Selectors restrict which Ingresses are watched; empty selectors match everything
+type Selectors struct {
+	IngressLabels   string // Label selector on Ingresses, e.g. "monitoring=enabled,tier!=internal"
+	IngressFields   string // Field selector on Ingresses, e.g. "metadata.namespace!=kube-system"
+	NamespaceLabels string // Label selector on the namespaces of Ingresses, e.g. "team=payments"
+}
+
+// Validate checks that the selectors can be parsed
+func (s Selectors) Validate() error {
+	var errs []error
+	if _, err := labels.Parse(s.IngressLabels); err != nil {
+		errs = append(errs, fmt.Errorf("invalid Ingress label selector %q: %w", s.IngressLabels, err))
+	}
+	if _, err := fields.ParseSelector(s.IngressFields); err != nil {
+		errs = append(errs, fmt.Errorf("invalid Ingress field selector %q: %w", s.IngressFields, err))
+	}
+	if _, err := labels.Parse(s.NamespaceLabels); err != nil {
+		errs = append(errs, fmt.Errorf("invalid namespace label selector %q: %w", s.NamespaceLabels, err))
+	}
+	return errors.Join(errs...)
+}
+
+// SetNamespaceFilter sets the namespace filtering mode and list. Namespaces may be
+// exact names, globs or /regex/ patterns. It is safe to call while endpoints are
+// being discovered.
+func (c *Client) SetNamespaceFilter(mode string, namespaces []string) error {
+	matcher, err := compileNamespacePatterns(namespaces)
+	if err != nil {
+		return err
+	}
+
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
 	c.namespaceMode = mode
 	c.namespaces = namespaces
+	c.namespaceList = matcher
+	return nil
+}
+
+// NamespaceSelection is the whole namespace filter of a client: the filtering mode
+// and list, and the allow and deny patterns that apply on top of them. A namespace
+// is discovered when it matches the allow list, or the allow list is empty, and
+// does not match the deny list.
+type NamespaceSelection struct {
+	Mode       string // "allow" or "deny"
+	Namespaces []string
+	Allow      []string
+	Deny       []string
+}
+
+// SetNamespaceSelection replaces the namespace filter and patterns at once, so that
+// a concurrent discovery never sees the new mode with the previous patterns. The
+// current selection is kept when any pattern is invalid.
+func (c *Client) SetNamespaceSelection(selection NamespaceSelection) error {
+	namespaceList, listErr := compileNamespacePatterns(selection.Namespaces)
+	allowList, allowErr := compileNamespacePatterns(selection.Allow)
+	denyList, denyErr := compileNamespacePatterns(selection.Deny)
+	if err := errors.Join(listErr, allowErr, denyErr); err != nil {
+		return err
+	}
+
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	c.namespaceMode = selection.Mode
+	c.namespaces = selection.Namespaces
+	c.namespaceList = namespaceList
+	c.allowList = allowList
+	c.denyList = denyList
+	return nil
 }
 
 // shouldProcessNamespace determines if a namespace should be processed based on the filtering mode
 func (c *Client) shouldProcessNamespace(namespace string) bool {
+	c.filterMu.RLock()
+	defer c.filterMu.RUnlock()
+
+	if !c.allowList.empty() && !c.allowList.matches(namespace) {
+		return false
+	}
+	if c.denyList.matches(namespace) {
+		return false
+	}
+
 	// If no namespaces are specified, follow the mode's default behavior
 	if len(c.namespaces) == 0 {
 		return c.namespaceMode == "allow" // Allow all if mode is "allow" and no namespaces specified
 	}
 
-	// Check if the namespace is in the list
-	found := false
-	for _, ns := range c.namespaces {
-		if ns == namespace {
-			found = true
-			break
-		}
-	}
+	// Check if the namespace matches the list
+	found := c.namespaceList.matches(namespace)
 
 	// If mode is "allow", only process namespaces in the list
 	// If mode is "deny", only process namespaces NOT in the list
 	return (c.namespaceMode == "allow" && found) || (c.namespaceMode == "deny" && !found)
 }
 
-// NewClient creates a new Kubernetes client
+// NewClient creates a new Kubernetes client using the in-cluster config, or the
+// default kubeconfig when running outside a cluster
 func NewClient() (*Client, error) {
-	// Try in-cluster config first
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		// Fall back to kubeconfig
-		home := homedir.HomeDir()
-		kubeconfigPath := filepath.Join(home, ".kube", "config")
+	return NewClientForCluster(ClusterOptions{})
+}
 
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-		if err != nil {
-			return nil, err
+// NewClientForClientset creates a client backed by an Ingress informer on the given
+// clientset, such as a fake clientset in tests. The selectors are passed to the API
+// server, so Ingresses they exclude are neither listed nor cached. Other routing
+// resources are not discovered, since they need a dynamic client.
+func NewClientForClientset(clientset kubernetes.Interface, opts ClusterOptions) *Client {
+	selectors := opts.Selectors
+	ingressFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selectors.IngressLabels
+			options.FieldSelector = selectors.IngressFields
+		}),
+	)
+	ingressInformer := ingressFactory.Networking().V1().Ingresses()
+
+	c := &Client{
+		cluster:       opts.Name,
+		clientset:     clientset,
+		classes:       opts.Classes,
+		probe:         opts.Probe,
+		ingressLister: ingressInformer.Lister(),
+		cacheSyncs:    []cache.InformerSynced{ingressInformer.Informer().HasSynced},
+		factories:     []informers.SharedInformerFactory{ingressFactory},
+		namespaceMode: "allow", // Default to allow all namespaces
+		namespaces:    []string{},
+	}
+
+	// Namespaces are watched separately since their selector differs from the Ingresses'
+	if selectors.NamespaceLabels != "" {
+		namespaceFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = selectors.NamespaceLabels
+			}),
+		)
+		namespaceInformer := namespaceFactory.Core().V1().Namespaces()
+		c.namespaceLister = namespaceInformer.Lister()
+		c.cacheSyncs = append(c.cacheSyncs, namespaceInformer.Informer().HasSynced)
+		c.factories = append(c.factories, namespaceFactory)
+	}
+
+	// IngressClasses resolve the class of Ingresses relying on the default class,
+	// which is reported even when no class filter is configured
+	classFactory := informers.NewSharedInformerFactory(clientset, 0)
+	classInformer := classFactory.Networking().V1().IngressClasses()
+	c.classLister = classInformer.Lister()
+	c.cacheSyncs = append(c.cacheSyncs, classInformer.Informer().HasSynced)
+	c.factories = append(c.factories, classFactory)
+
+	if opts.Backends.Enabled || opts.Backends.Pods {
+		c.watchBackends(opts.Backends)
+	}
+	if opts.Services.Enabled {
+		c.watchServices(opts.Services)
+	}
+	c.watchResources(opts.Sources)
+
+	return c
+}
+
+// Cluster returns the name of the cluster this client discovers endpoints in
+func (c *Client) Cluster() string {
+	return c.cluster
+}
+
+// Clientset returns the underlying Kubernetes clientset
+func (c *Client) Clientset() kubernetes.Interface {
+	return c.clientset
+}
+
+// Start starts watching Ingresses, and the namespaces, IngressClasses, Services or
+// other routing resources it needs, until the context is cancelled. Routing
+// resources are watched once their API is found in the cluster.
+func (c *Client) Start(ctx context.Context) {
+	for _, factory := range c.factories {
+		factory.Start(ctx.Done())
+	}
+	for _, source := range c.resources {
+		go c.startResource(ctx, source)
+	}
+}
+
+// WaitForSync blocks until the caches have synced or the context is cancelled
+func (c *Client) WaitForSync(ctx context.Context) bool {
+	return cache.WaitForCacheSync(ctx.Done(), c.cacheSyncs...)
+}
+
+// HasSynced reports whether the caches have been fully populated
+func (c *Client) HasSynced() bool {
+	for _, synced := range c.cacheSyncs {
+		if !synced() {
+			return false
 		}
 	}
+	return true
+}
+
+// DiscoverEndpoints discovers the endpoints of every enabled source, Ingresses,
+// annotated Services and other routing resources, from the informer caches
+func (c *Client) DiscoverEndpoints(ctx context.Context) ([]Endpoint, error) {
+	logging.Debugf("Discovering endpoints in cluster %q", c.cluster)
+
+	if !c.HasSynced() {
+		return nil, ErrNotSynced
+	}
 
-	// Create the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	endpoints, skipped, err := c.ingressEndpoints()
 	if err != nil {
 		return nil, err
 	}
+	if c.serviceLister != nil {
+		serviceEndpoints, serviceSkipped, err := c.serviceEndpoints()
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, serviceEndpoints...)
+		skipped = append(skipped, serviceSkipped...)
+	}
+	resourceEndpoints, resourceSkipped := c.resourceEndpoints()
+	endpoints = append(endpoints, resourceEndpoints...)
+	skipped = append(skipped, resourceSkipped...)
 
-	return &Client{
-		clientset: clientset,
-		namespaceMode: "allow", // Default to allow all namespaces
-		namespaces: []string{},
-	}, nil
+	c.skippedMu.Lock()
+	c.skipped = skipped
+	c.skippedMu.Unlock()
+
+	logging.Debugf("Discovered %d endpoints in cluster %q, skipped %d rules", len(endpoints), c.cluster, len(skipped))
+	return endpoints, nil
 }
 
-// DiscoverIngressEndpoints discovers all Ingress endpoints
-func (c *Client) DiscoverIngressEndpoints(ctx context.Context) ([]Endpoint, error) {
-	log.Println("Discovering Ingress endpoints")
+// namespaceSelected reports whether endpoints are discovered in the namespace,
+// according to the namespace filter and label selector
+func (c *Client) namespaceSelected(namespace string) bool {
+	if !c.shouldProcessNamespace(namespace) {
+		return false
+	}
+	// Only namespaces matching the label selector are in the namespace cache
+	if c.namespaceLister != nil {
+		if _, err := c.namespaceLister.Get(namespace); err != nil {
+			return false
+		}
+	}
+	return true
+}
 
+// ingressEndpoints returns the endpoints of all selected Ingresses and the rules
+// that cannot be probed
+func (c *Client) ingressEndpoints() ([]Endpoint, []SkippedRule, error) {
 	// List all ingresses across all namespaces
-	ingresses, err := c.clientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	ingresses, err := c.ingressLister.List(labels.Everything())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var endpoints []Endpoint
+	var skipped []SkippedRule
+	defaultClass := c.defaultIngressClass()
 
 	// Process each ingress
-	for _, ingress := range ingresses.Items {
+	for _, ingress := range ingresses {
 		// Apply namespace filtering
-		if !c.shouldProcessNamespace(ingress.Namespace) {
+		if !c.namespaceSelected(ingress.Namespace) {
+			continue
+		}
+
+		class := ingressClassOf(ingress, defaultClass)
+		if !c.classes.includes(class) {
 			continue
 		}
 
-		eps := extractEndpointsFromIngress(ingress)
-		endpoints = append(endpoints, eps...)
+		ingressEndpoints, ingressSkipped := c.endpointsFromIngress(ingress, class)
+		endpoints = append(endpoints, ingressEndpoints...)
+		skipped = append(skipped, ingressSkipped...)
 	}
+	return endpoints, skipped, nil
+}
 
-	log.Printf("Discovered %d endpoints from ingresses", len(endpoints))
-	return endpoints, nil
+// SkippedRules returns the rules skipped by the last discovery
+func (c *Client) SkippedRules() []SkippedRule {
+	c.skippedMu.RLock()
+	defer c.skippedMu.RUnlock()
+	return append([]SkippedRule(nil), c.skipped...)
+}
+
+// endpointsFromIngress extracts the endpoints of an Ingress of the given class and
+// applies the class settings
+func (c *Client) endpointsFromIngress(ingress *networkingv1.Ingress, class string) ([]Endpoint, []SkippedRule) {
+	settings := c.classes.Settings[class]
+
+	endpoints, skipped := extractEndpointsFromIngress(*ingress)
+	for i := range skipped {
+		skipped[i].Cluster = c.cluster
+	}
+	for i := range endpoints {
+		endpoints[i].Cluster = c.cluster
+		endpoints[i].IngressClass = class
+		c.setProbeAddress(ingress, &endpoints[i], settings)
+		// An annotation on the Ingress takes precedence over the class setting
+		if settings.Scheme != "" && annotatedScheme(ingress.Annotations) == "" {
+			if _, rest, ok := strings.Cut(endpoints[i].URL, "://"); ok {
+				endpoints[i].URL = settings.Scheme + "://" + rest
+			}
+		}
+	}
+	return endpoints, skipped
+}
+
+// setProbeAddress selects the address the endpoint is probed at. Host-less rules
+// are always probed through the load balancer, and a class probe address takes
+// precedence over the probe mode. When the load balancer has no address yet, the
+// URL host is resolved instead.
+func (c *Client) setProbeAddress(ingress *networkingv1.Ingress, endpoint *Endpoint, settings IngressClassSettings) {
+	switch {
+	case endpoint.ProbeMode != "":
+		// Already determined by the rule
+	case settings.ProbeAddress != "":
+		endpoint.ProbeMode = ProbeModeController
+		endpoint.ProbeAddress = settings.ProbeAddress
+	case c.probe.Mode == ProbeModeController && c.probe.ControllerAddress != "":
+		endpoint.ProbeMode = ProbeModeController
+		endpoint.ProbeAddress = c.probe.ControllerAddress
+	case c.probe.Mode == ProbeModeLoadBalancer && loadBalancerAddress(*ingress) != "":
+		endpoint.ProbeMode = ProbeModeLoadBalancer
+		endpoint.ProbeAddress = loadBalancerAddress(*ingress)
+	default:
+		endpoint.ProbeMode = ProbeModeDNS
+	}
 }
 
-func extractEndpointsFromIngress(ingress networkingv1.Ingress) []Endpoint {
+// ingressClassOf returns the class of an Ingress from spec.ingressClassName, the
+// legacy annotation, or the default class
+func ingressClassOf(ingress *networkingv1.Ingress, defaultClass string) string {
+	if ingress.Spec.IngressClassName != nil && *ingress.Spec.IngressClassName != "" {
+		return *ingress.Spec.IngressClassName
+	}
+	if class := ingress.Annotations[ingressClassAnnotation]; class != "" {
+		return class
+	}
+	return defaultClass
+}
+
+// defaultIngressClass returns the IngressClass marked as the cluster default, if any
+func (c *Client) defaultIngressClass() string {
+	if c.classLister == nil {
+		return ""
+	}
+	classes, err := c.classLister.List(labels.Everything())
+	if err != nil {
+		return ""
+	}
+	for _, class := range classes {
+		if class.Annotations[networkingv1.AnnotationIsDefaultIngressClass] == "true" {
+			return class.Name
+		}
+	}
+	return ""
+}
+
+// extractEndpointsFromIngress returns an endpoint for every path of the Ingress
+// rules and for the default backend, and the rules and paths that cannot be probed
+func extractEndpointsFromIngress(ingress networkingv1.Ingress) ([]Endpoint, []SkippedRule) {
 	namespace := ingress.Namespace
 	name := ingress.Name
 	annotations := ingress.Annotations
 	labels := ingress.Labels
-
-	// Check if TLS is configured
-	tls := len(ingress.Spec.TLS) > 0
-	protocol := "http"
-	if tls {
-		protocol = "https"
-	}
+	regex := usesRegexPaths(annotations)
 
 	var endpoints []Endpoint
+	var skipped []SkippedRule
+	skip := func(host string, path *networkingv1.HTTPIngressPath, reason string) {
+		rule := SkippedRule{Source: SourceIngress, Namespace: namespace, IngressName: name, Host: host, Reason: reason}
+		if path != nil {
+			rule.Path = path.Path
+			if path.PathType != nil {
+				rule.PathType = string(*path.PathType)
+			}
+		}
+		skipped = append(skipped, rule)
+	}
 
 	// Process each rule
 	for _, rule := range ingress.Spec.Rules {
-		if rule.Host == "" {
-			continue
-		}
-
 		host := rule.Host
 
 		// Process each path
 		if rule.HTTP == nil {
+			skip(host, nil, "rule has no HTTP paths")
 			continue
 		}
 
-		for _, path := range rule.HTTP.Paths {
-			pathType := string(*path.PathType)
-			if pathType == "" {
-				pathType = "Prefix"
-			}
+		// Rules without a host are probed through the load balancer
+		url, probeAddress, err := ruleTarget(ingress, host)
+		if err != nil {
+			skip(host, nil, err.Error())
+			continue
+		}
 
-			routePath := path.Path
-			if routePath == "" {
-				routePath = "/"
-			}
+		var probeMode ProbeMode
+		if host == "" {
+			probeMode = ProbeModeLoadBalancer
+		}
 
+		for i, path := range rule.HTTP.Paths {
 			// Extract service name
 			if path.Backend.Service == nil {
+				skip(host, &rule.HTTP.Paths[i], "backend is not a service")
 				continue
 			}
 			serviceName := path.Backend.Service.Name
 
-			// Build the URL
-			url := protocol + "://" + host
-
-			// Check for health endpoint annotation
-			healthEndpoint := routePath
-			if pathSpecificHealth, ok := annotations["health.monitor/path."+serviceName]; ok {
-				healthEndpoint = pathSpecificHealth
-			} else if generalHealth, ok := annotations["health.monitor/endpoint"]; ok {
-				healthEndpoint = generalHealth
+			// Check for health endpoint annotation, otherwise probe the route itself
+			healthEndpoint, ok := annotatedHealthPath(annotations, serviceName)
+			if !ok {
+				routePath, err := probePath(path, regex)
+				if err != nil {
+					skip(host, &rule.HTTP.Paths[i], err.Error())
+					continue
+				}
+				healthEndpoint = routePath
 			}
 
 			endpoints = append(endpoints, Endpoint{
-				Namespace:   namespace,
-				ServiceName: serviceName,
-				IngressName: name,
-				URL:         url,
-				Path:        healthEndpoint,
-				Labels:      labels,
-				Annotations: annotations,
+				Source:          SourceIngress,
+				Namespace:       namespace,
+				ServiceName:     serviceName,
+				ServicePort:     path.Backend.Service.Port.Number,
+				ServicePortName: path.Backend.Service.Port.Name,
+				IngressName:     name,
+				URL:             url,
+				Path:            healthEndpoint,
+				ProbeAddress:    probeAddress,
+				ProbeMode:       probeMode,
+				Labels:          labels,
+				Annotations:     annotations,
 			})
 		}
 	}
 
-	return endpoints
+	// The default backend serves requests that match no rule
+	if backend := ingress.Spec.DefaultBackend; backend != nil {
+		url, probeAddress, err := ruleTarget(ingress, "")
+		switch {
+		case backend.Service == nil:
+			skip("", nil, "default backend is not a service")
+		case err != nil:
+			skip("", nil, "default backend: "+err.Error())
+		default:
+			healthEndpoint, ok := annotatedHealthPath(annotations, backend.Service.Name)
+			if !ok {
+				healthEndpoint = "/"
+			}
+			endpoints = append(endpoints, Endpoint{
+				Source:          SourceIngress,
+				Namespace:       namespace,
+				ServiceName:     backend.Service.Name,
+				ServicePort:     backend.Service.Port.Number,
+				ServicePortName: backend.Service.Port.Name,
+				IngressName:     name,
+				URL:             url,
+				Path:            healthEndpoint,
+				ProbeAddress:    probeAddress,
+				ProbeMode:       ProbeModeLoadBalancer,
+				Labels:          labels,
+				Annotations:     annotations,
+			})
+		}
+	}
+
+	return endpoints, skipped
+}
+
+// annotatedHealthPath returns the path set by the health annotations for the service
+func annotatedHealthPath(annotations map[string]string, serviceName string) (string, bool) {
+	if pathSpecificHealth, ok := annotations["health.monitor/path."+serviceName]; ok {
+		return pathSpecificHealth, true
+	}
+	if generalHealth, ok := annotations["health.monitor/endpoint"]; ok {
+		return generalHealth, true
+	}
+	return "", false
+}
+
+// ruleTarget returns the URL, without path, and the optional probe address of a
+// rule. Wildcard hosts are replaced by a concrete host from the annotation, and
+// rules without a host are probed at the load balancer address of the Ingress.
+func ruleTarget(ingress networkingv1.Ingress, host string) (string, string, error) {
+	if host != "" {
+		concrete, err := concreteHost(ingress.Annotations, host)
+		if err != nil {
+			return "", "", err
+		}
+		return ruleScheme(ingress, concrete) + "://" + concrete, "", nil
+	}
+
+	address := loadBalancerAddress(ingress)
+	if address == "" {
+		return "", "", errors.New("rule has no host and the Ingress status has no load balancer address")
+	}
+	if hostHeader := strings.TrimSpace(ingress.Annotations[AnnotationHostHeader]); hostHeader != "" {
+		// Keep the Host header and TLS server name by dialing the address instead
+		return ruleScheme(ingress, hostHeader) + "://" + hostHeader, address, nil
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		address = "[" + address + "]"
+	}
+	return ruleScheme(ingress, "") + "://" + address, "", nil
+}
+
+// concreteHost returns the host to probe for a rule host. A wildcard host is
+// replaced by the first host of the annotation that it covers.
+func concreteHost(annotations map[string]string, host string) (string, error) {
+	if !strings.HasPrefix(host, "*.") {
+		return host, nil
+	}
+	for _, candidate := range strings.Split(annotations[AnnotationWildcardHost], ",") {
+		if candidate = strings.TrimSpace(candidate); candidate != "" && matchesTLSHost(host, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("wildcard host %q needs a matching concrete host in the %s annotation", host, AnnotationWildcardHost)
+}
+
+// loadBalancerAddress returns the first IP or hostname of the Ingress load balancer status
+func loadBalancerAddress(ingress networkingv1.Ingress) string {
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			return lb.IP
+		}
+		if lb.Hostname != "" {
+			return lb.Hostname
+		}
+	}
+	return ""
+}
+
+// ruleScheme returns the scheme used to probe a host of the Ingress: the scheme
+// annotation when set, otherwise https when a TLS entry covers the host
+func ruleScheme(ingress networkingv1.Ingress, host string) string {
+	if scheme := annotatedScheme(ingress.Annotations); scheme != "" {
+		return scheme
+	}
+	for _, tls := range ingress.Spec.TLS {
+		// A TLS entry without hosts applies to every host of the Ingress
+		if len(tls.Hosts) == 0 {
+			return "https"
+		}
+		for _, tlsHost := range tls.Hosts {
+			if matchesTLSHost(tlsHost, host) {
+				return "https"
+			}
+		}
+	}
+	return "http"
+}
+
+// annotatedScheme returns the scheme forced by the annotation, empty when unset or invalid
+func annotatedScheme(annotations map[string]string) string {
+	switch scheme := strings.ToLower(strings.TrimSpace(annotations[AnnotationScheme])); scheme {
+	case "http", "https":
+		return scheme
+	default:
+		return ""
+	}
+}
+
+// matchesTLSHost reports whether a TLS host, possibly a wildcard such as
+// "*.example.com", covers the host. Like certificates, a wildcard only matches a
+// single label.
+func matchesTLSHost(tlsHost, host string) bool {
+	tlsHost, host = strings.ToLower(tlsHost), strings.ToLower(host)
+	if tlsHost == host {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(tlsHost, "*.")
+	if !ok {
+		return false
+	}
+	_, parent, ok := strings.Cut(host, ".")
+	return ok && parent == suffix
 }
@@ -1,10 +1,14 @@
 package discovery
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestShouldProcessNamespace(t *testing.T) {
@@ -57,13 +61,27 @@ func TestShouldProcessNamespace(t *testing.T) {
 			testNamespace:  "default",
 			expectedResult: true,
 		},
+		{
+			name:           "allow mode with matching glob",
+			namespaceMode:  "allow",
+			namespaces:     []string{"team-*-prod"},
+			testNamespace:  "team-payments-prod",
+			expectedResult: true,
+		},
+		{
+			name:           "deny mode with matching regex",
+			namespaceMode:  "deny",
+			namespaces:     []string{"/^kube-/"},
+			testNamespace:  "kube-public",
+			expectedResult: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := &Client{
-				namespaceMode: tt.namespaceMode,
-				namespaces:    tt.namespaces,
+			client := &Client{}
+			if err := client.SetNamespaceFilter(tt.namespaceMode, tt.namespaces); err != nil {
+				t.Fatalf("SetNamespaceFilter() error = %v", err)
 			}
 
 			result := client.shouldProcessNamespace(tt.testNamespace)
@@ -96,6 +114,69 @@ func TestSetNamespaceFilter(t *testing.T) {
 	}
 }
 
+func TestSetNamespacePatterns(t *testing.T) {
+	client := &Client{}
+	if err := client.SetNamespaceSelection(NamespaceSelection{
+		Mode:  "allow",
+		Allow: []string{"*-prod"},
+		Deny:  []string{"*-sandbox-prod", "/^legacy-/"},
+	}); err != nil {
+		t.Fatalf("SetNamespaceSelection() error = %v", err)
+	}
+
+	tests := map[string]bool{
+		"payments-prod":         true,
+		"payments-sandbox-prod": false,
+		"legacy-billing-prod":   false,
+		"payments-staging":      false,
+	}
+	for namespace, expected := range tests {
+		if result := client.shouldProcessNamespace(namespace); result != expected {
+			t.Errorf("shouldProcessNamespace(%q) = %v, want %v", namespace, result, expected)
+		}
+	}
+
+	// Invalid patterns are rejected and the current ones kept
+	if err := client.SetNamespaceSelection(NamespaceSelection{Mode: "allow", Allow: []string{"/(/", "team-["}}); err == nil {
+		t.Errorf("Expected an error for invalid patterns")
+	}
+	if !client.shouldProcessNamespace("payments-prod") {
+		t.Errorf("Expected the previous patterns to remain after an invalid update")
+	}
+}
+
+func TestSetNamespaceSelection(t *testing.T) {
+	client := &Client{}
+	if err := client.SetNamespaceSelection(NamespaceSelection{
+		Mode:       "deny",
+		Namespaces: []string{"kube-*"},
+		Allow:      []string{"*-prod", "kube-*"},
+		Deny:       []string{"/^legacy-/"},
+	}); err != nil {
+		t.Fatalf("SetNamespaceSelection() error = %v", err)
+	}
+
+	tests := map[string]bool{
+		"payments-prod":       true,
+		"kube-system":         false,
+		"legacy-billing-prod": false,
+		"payments-staging":    false,
+	}
+	for namespace, expected := range tests {
+		if result := client.shouldProcessNamespace(namespace); result != expected {
+			t.Errorf("shouldProcessNamespace(%q) = %v, want %v", namespace, result, expected)
+		}
+	}
+
+	// An invalid pattern anywhere keeps the whole current selection
+	if err := client.SetNamespaceSelection(NamespaceSelection{Mode: "allow", Deny: []string{"/(/"}}); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+	if client.namespaceMode != "deny" || client.shouldProcessNamespace("kube-system") {
+		t.Errorf("Expected the previous selection to remain after an invalid update")
+	}
+}
+
 func TestExtractEndpointsFromIngress(t *testing.T) {
 	// Create a test ingress
 	pathType := networkingv1.PathTypePrefix
@@ -161,7 +242,7 @@ func TestExtractEndpointsFromIngress(t *testing.T) {
 					},
 				},
 				{
-					// Rule without host should be skipped
+					// Rule without host is skipped without a load balancer address
 					IngressRuleValue: networkingv1.IngressRuleValue{
 						HTTP: &networkingv1.HTTPIngressRuleValue{
 							Paths: []networkingv1.HTTPIngressPath{
@@ -190,12 +271,16 @@ func TestExtractEndpointsFromIngress(t *testing.T) {
 	}
 
 	// Extract endpoints
-	endpoints := extractEndpointsFromIngress(ingress)
+	endpoints, skipped := extractEndpointsFromIngress(ingress)
 
 	// Check the number of endpoints
 	if len(endpoints) != 2 {
 		t.Fatalf("Expected 2 endpoints, got %d", len(endpoints))
 	}
+	// Without a load balancer address, the rule without host cannot be probed
+	if len(skipped) != 2 || skipped[0].Host != "" || skipped[1].Host != "no-http.example.com" {
+		t.Errorf("Expected the rules without host and HTTP to be skipped, got %+v", skipped)
+	}
 
 	// Check the endpoints
 	// Sort the endpoints by URL to ensure consistent order
@@ -203,9 +288,9 @@ func TestExtractEndpointsFromIngress(t *testing.T) {
 		endpoints[0], endpoints[1] = endpoints[1], endpoints[0]
 	}
 
-	// Check the first endpoint
-	if endpoints[0].URL != "https://example.com" {
-		t.Errorf("Expected URL 'https://example.com', got '%s'", endpoints[0].URL)
+	// Check the first endpoint, whose host is not covered by the TLS section
+	if endpoints[0].URL != "http://example.com" {
+		t.Errorf("Expected URL 'http://example.com', got '%s'", endpoints[0].URL)
 	}
 	if endpoints[0].ServiceName != "service" {
 		t.Errorf("Expected ServiceName 'service', got '%s'", endpoints[0].ServiceName)
@@ -233,3 +318,348 @@ func TestExtractEndpointsFromIngress(t *testing.T) {
 		t.Errorf("Expected Path '/secure' or '/service-health', got '%s'", endpoints[1].Path)
 	}
 }
+
+func TestExtractEndpointsFromIngressWithoutHost(t *testing.T) {
+	ingress := *newTestIngress("default", "catch-all", "", nil)
+	ingress.Spec.DefaultBackend = &networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{Name: "fallback"},
+	}
+
+	// Nothing can be probed before the load balancer is provisioned
+	if endpoints, skipped := extractEndpointsFromIngress(ingress); len(endpoints) != 0 || len(skipped) != 2 {
+		t.Fatalf("Expected the rule and default backend to be skipped, got %+v and %+v", endpoints, skipped)
+	}
+
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "203.0.113.10"}}
+	endpoints, skipped := extractEndpointsFromIngress(ingress)
+	if len(endpoints) != 2 || len(skipped) != 0 {
+		t.Fatalf("Expected the rule and default backend to be probed, got %+v and %+v", endpoints, skipped)
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.URL != "http://203.0.113.10" || endpoint.ProbeAddress != "" {
+			t.Errorf("Expected the load balancer address as URL, got %q and probe address %q", endpoint.URL, endpoint.ProbeAddress)
+		}
+	}
+	if endpoints[1].ServiceName != "fallback" || endpoints[1].Path != "/" {
+		t.Errorf("Expected the default backend at /, got %+v", endpoints[1])
+	}
+
+	// With a Host header, the URL keeps the host and the load balancer is dialed
+	ingress.Annotations = map[string]string{AnnotationHostHeader: "shop.example.com"}
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{Hostname: "lb.example.net"}}
+	endpoints, _ = extractEndpointsFromIngress(ingress)
+	if len(endpoints) != 2 || endpoints[0].URL != "http://shop.example.com" || endpoints[0].ProbeAddress != "lb.example.net" {
+		t.Errorf("Expected the Host header in the URL and the load balancer as probe address, got %+v", endpoints)
+	}
+}
+
+func TestExtractEndpointsFromIngressWildcardHost(t *testing.T) {
+	ingress := *newTestIngress("default", "tenants", "*.example.com", nil)
+	ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}}
+
+	if endpoints, skipped := extractEndpointsFromIngress(ingress); len(endpoints) != 0 || len(skipped) != 1 {
+		t.Fatalf("Expected the wildcard rule to be skipped without annotation, got %+v and %+v", endpoints, skipped)
+	}
+
+	ingress.Annotations = map[string]string{AnnotationWildcardHost: "www.other.com, demo.example.com"}
+	endpoints, _ := extractEndpointsFromIngress(ingress)
+	if len(endpoints) != 1 || endpoints[0].URL != "https://demo.example.com" {
+		t.Errorf("Expected the matching concrete host over https, got %+v", endpoints)
+	}
+}
+
+func TestSetProbeAddress(t *testing.T) {
+	ingress := newTestIngress("default", "web", "web.example.com", nil)
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "203.0.113.10"}}
+	controller := "ingress-nginx-controller.ingress-nginx.svc"
+
+	tests := []struct {
+		name            string
+		probe           ProbeOptions
+		settings        IngressClassSettings
+		noLoadBalancer  bool
+		expectedMode    ProbeMode
+		expectedAddress string
+	}{
+		{"dns", ProbeOptions{Mode: ProbeModeDNS}, IngressClassSettings{}, false, ProbeModeDNS, ""},
+		{"load balancer", ProbeOptions{Mode: ProbeModeLoadBalancer}, IngressClassSettings{}, false, ProbeModeLoadBalancer, "203.0.113.10"},
+		{"load balancer pending", ProbeOptions{Mode: ProbeModeLoadBalancer}, IngressClassSettings{}, true, ProbeModeDNS, ""},
+		{"controller", ProbeOptions{Mode: ProbeModeController, ControllerAddress: controller}, IngressClassSettings{}, false, ProbeModeController, controller},
+		{"class address", ProbeOptions{Mode: ProbeModeLoadBalancer}, IngressClassSettings{ProbeAddress: "traefik.traefik.svc"}, false, ProbeModeController, "traefik.traefik.svc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := ingress.DeepCopy()
+			if tt.noLoadBalancer {
+				target.Status = networkingv1.IngressStatus{}
+			}
+			client := &Client{probe: tt.probe}
+			endpoint := Endpoint{URL: "https://web.example.com"}
+			client.setProbeAddress(target, &endpoint, tt.settings)
+			if endpoint.ProbeMode != tt.expectedMode || endpoint.ProbeAddress != tt.expectedAddress {
+				t.Errorf("Expected mode %q and address %q, got %q and %q", tt.expectedMode, tt.expectedAddress, endpoint.ProbeMode, endpoint.ProbeAddress)
+			}
+		})
+	}
+
+	// Host-less rules keep the load balancer they were resolved to
+	endpoint := Endpoint{URL: "http://203.0.113.10", ProbeMode: ProbeModeLoadBalancer}
+	(&Client{probe: ProbeOptions{Mode: ProbeModeController, ControllerAddress: controller}}).setProbeAddress(ingress, &endpoint, IngressClassSettings{})
+	if endpoint.ProbeMode != ProbeModeLoadBalancer || endpoint.ProbeAddress != "" {
+		t.Errorf("Expected the rule's probe mode to be kept, got %q and %q", endpoint.ProbeMode, endpoint.ProbeAddress)
+	}
+}
+
+func TestParseProbeMode(t *testing.T) {
+	if mode, err := ParseProbeMode(""); err != nil || mode != ProbeModeDNS {
+		t.Errorf("Expected dns by default, got %q, %v", mode, err)
+	}
+	if mode, err := ParseProbeMode("LoadBalancer"); err != nil || mode != ProbeModeLoadBalancer {
+		t.Errorf("Expected loadbalancer, got %q, %v", mode, err)
+	}
+	if _, err := ParseProbeMode("direct"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestRuleScheme(t *testing.T) {
+	tests := []struct {
+		name        string
+		tls         []networkingv1.IngressTLS
+		annotations map[string]string
+		host        string
+		expected    string
+	}{
+		{"no TLS", nil, nil, "web.example.com", "http"},
+		{"listed host", []networkingv1.IngressTLS{{Hosts: []string{"api.example.com", "web.example.com"}}}, nil, "web.example.com", "https"},
+		{"other host", []networkingv1.IngressTLS{{Hosts: []string{"api.example.com"}}}, nil, "web.example.com", "http"},
+		{"wildcard", []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}}, nil, "Web.Example.com", "https"},
+		{"wildcard single label", []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}}, nil, "a.web.example.com", "http"},
+		{"wildcard apex", []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}}, nil, "example.com", "http"},
+		{"entry without hosts", []networkingv1.IngressTLS{{SecretName: "default-cert"}}, nil, "web.example.com", "https"},
+		{"annotation forces http", []networkingv1.IngressTLS{{Hosts: []string{"web.example.com"}}}, map[string]string{AnnotationScheme: "http"}, "web.example.com", "http"},
+		{"annotation forces https", nil, map[string]string{AnnotationScheme: "HTTPS"}, "web.example.com", "https"},
+		{"invalid annotation", nil, map[string]string{AnnotationScheme: "ftp"}, "web.example.com", "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       networkingv1.IngressSpec{TLS: tt.tls},
+			}
+			if scheme := ruleScheme(ingress, tt.host); scheme != tt.expected {
+				t.Errorf("ruleScheme(%q) = %q, expected %q", tt.host, scheme, tt.expected)
+			}
+		})
+	}
+}
+
+// newTestIngress creates an Ingress routing / on the host to a service named after the Ingress
+func newTestIngress(namespace, name, host string, labels map[string]string) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{Name: name},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDiscoverIngressEndpoints(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(
+		newTestIngress("default", "web", "web.example.com", nil),
+		newTestIngress("kube-system", "dashboard", "dashboard.example.com", nil),
+	), ClusterOptions{Name: "prod-eu"})
+	client.SetNamespaceFilter("deny", []string{"kube-system"})
+
+	// Endpoints are not available before the cache has synced
+	if _, err := client.DiscoverEndpoints(context.Background()); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("Expected ErrNotSynced before the cache synced, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Ingress cache did not sync")
+	}
+
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].URL != "http://web.example.com" {
+		t.Fatalf("Expected only the web.example.com endpoint, got %+v", endpoints)
+	}
+	if endpoints[0].Cluster != "prod-eu" {
+		t.Errorf("Expected endpoint cluster %q, got %q", "prod-eu", endpoints[0].Cluster)
+	}
+}
+
+func TestDiscoverIngressEndpointsSelectors(t *testing.T) {
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	monitored := map[string]string{"monitoring": "enabled"}
+
+	client := NewClientForClientset(fake.NewClientset(
+		newNamespace("payments", map[string]string{"team": "payments"}),
+		newNamespace("search", map[string]string{"team": "search"}),
+		newTestIngress("payments", "checkout", "checkout.example.com", monitored),
+		newTestIngress("payments", "admin", "admin.example.com", nil),
+		newTestIngress("search", "query", "query.example.com", monitored),
+	), ClusterOptions{Selectors: Selectors{IngressLabels: "monitoring=enabled", NamespaceLabels: "team=payments"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].IngressName != "checkout" {
+		t.Fatalf("Expected only the labeled Ingress in the selected namespace, got %+v", endpoints)
+	}
+}
+
+func TestSelectorsValidate(t *testing.T) {
+	if err := (Selectors{IngressLabels: "tier!=internal", IngressFields: "metadata.namespace!=kube-system"}).Validate(); err != nil {
+		t.Errorf("Expected valid selectors, got %v", err)
+	}
+	if err := (Selectors{IngressLabels: "tier in (", NamespaceLabels: "team=="}).Validate(); err == nil {
+		t.Errorf("Expected an error for invalid selectors")
+	}
+}
+
+func TestDiscoverIngressEndpointsClasses(t *testing.T) {
+	nginx, traefik := "nginx", "traefik"
+	withClassName := func(ingress *networkingv1.Ingress, class *string) *networkingv1.Ingress {
+		ingress.Spec.IngressClassName = class
+		return ingress
+	}
+	legacy := newTestIngress("default", "legacy", "legacy.example.com", nil)
+	legacy.Annotations = map[string]string{ingressClassAnnotation: "traefik"}
+
+	client := NewClientForClientset(fake.NewClientset(
+		&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{networkingv1.AnnotationIsDefaultIngressClass: "true"},
+		}},
+		withClassName(newTestIngress("default", "web", "web.example.com", nil), &nginx),
+		withClassName(newTestIngress("default", "api", "api.example.com", nil), &traefik),
+		newTestIngress("default", "unclassed", "unclassed.example.com", nil),
+		legacy,
+	), ClusterOptions{Classes: IngressClasses{
+		Include: []string{"nginx"},
+		Settings: map[string]IngressClassSettings{
+			"nginx": {Scheme: "https", ProbeAddress: "ingress-nginx-controller.ingress-nginx.svc"},
+		},
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+
+	// The Ingress without a class belongs to the default class
+	found := make(map[string]Endpoint)
+	for _, endpoint := range endpoints {
+		found[endpoint.IngressName] = endpoint
+	}
+	if len(found) != 2 || found["web"].IngressName == "" || found["unclassed"].IngressName == "" {
+		t.Fatalf("Expected the nginx and default class Ingresses, got %+v", endpoints)
+	}
+	for _, endpoint := range found {
+		if endpoint.IngressClass != "nginx" {
+			t.Errorf("Expected ingress class %q, got %q", "nginx", endpoint.IngressClass)
+		}
+		if endpoint.ProbeAddress != "ingress-nginx-controller.ingress-nginx.svc" {
+			t.Errorf("Expected the class probe address, got %q", endpoint.ProbeAddress)
+		}
+	}
+	if found["web"].URL != "https://web.example.com" {
+		t.Errorf("Expected the class scheme to be applied, got %q", found["web"].URL)
+	}
+}
+
+func TestDiscoverIngressEndpointsDefaultClass(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(
+		&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{networkingv1.AnnotationIsDefaultIngressClass: "true"},
+		}},
+		newTestIngress("default", "unclassed", "unclassed.example.com", nil),
+	), ClusterOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	// The default class is reported without any class filter
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].IngressClass != "nginx" {
+		t.Errorf("Expected the Ingress in the default class %q, got %+v", "nginx", endpoints)
+	}
+}
+
+func TestIngressClassOf(t *testing.T) {
+	nginx, empty := "nginx", ""
+	tests := []struct {
+		name        string
+		className   *string
+		annotations map[string]string
+		expected    string
+	}{
+		{"spec", &nginx, map[string]string{ingressClassAnnotation: "traefik"}, "nginx"},
+		{"annotation", nil, map[string]string{ingressClassAnnotation: "traefik"}, "traefik"},
+		{"empty spec", &empty, map[string]string{ingressClassAnnotation: "traefik"}, "traefik"},
+		{"default", nil, nil, "default-class"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := newTestIngress("default", "web", "web.example.com", nil)
+			ingress.Spec.IngressClassName = tt.className
+			ingress.Annotations = tt.annotations
+			if class := ingressClassOf(ingress, "default-class"); class != tt.expected {
+				t.Errorf("ingressClassOf() = %q, expected %q", class, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SourceVirtualService endpoints are Istio VirtualServices bound to gateways
+const SourceVirtualService Source = "virtualservice"
+
+// virtualServiceResource is the Istio VirtualService API
+var virtualServiceResource = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "virtualservices"}
+
+// meshGateway is the reserved gateway name of the sidecars of the mesh
+const meshGateway = "mesh"
+
+// extractEndpointsFromVirtualService builds an endpoint for every host and HTTP
+// route of a VirtualService bound to a gateway. The URI match of a route gives the
+// path, and VirtualServices only serving the mesh are ignored since they are not
+// reachable from outside it.
+func extractEndpointsFromVirtualService(virtualService *unstructured.Unstructured, probe ProbeOptions) ([]Endpoint, []SkippedRule) {
+	namespace := virtualService.GetNamespace()
+	name := virtualService.GetName()
+	annotations := virtualService.GetAnnotations()
+
+	var skipped []SkippedRule
+	skip := func(host, path, reason string) {
+		skipped = append(skipped, SkippedRule{
+			Source:      SourceVirtualService,
+			Namespace:   namespace,
+			IngressName: name,
+			Host:        host,
+			Path:        path,
+			Reason:      reason,
+		})
+	}
+
+	gateways, _, _ := unstructured.NestedStringSlice(virtualService.Object, "spec", "gateways")
+	if !boundToGateway(gateways) {
+		return nil, nil
+	}
+
+	// The gateway servers are not resolved, so the scheme defaults to http unless
+	// the VirtualService routes TLS itself
+	scheme := "http"
+	if tls, found, _ := unstructured.NestedSlice(virtualService.Object, "spec", "tls"); found && len(tls) > 0 {
+		scheme = "https"
+	}
+	if annotated := annotatedScheme(annotations); annotated != "" {
+		scheme = annotated
+	}
+
+	var hosts []string
+	specHosts, _, _ := unstructured.NestedStringSlice(virtualService.Object, "spec", "hosts")
+	for _, host := range specHosts {
+		if host == "*" || !strings.Contains(host, ".") {
+			skip(host, "", "host is not a fully qualified domain name")
+			continue
+		}
+		concrete, err := concreteHost(annotations, host)
+		if err != nil {
+			skip(host, "", err.Error())
+			continue
+		}
+		hosts = append(hosts, concrete)
+	}
+
+	routes, _, _ := unstructured.NestedSlice(virtualService.Object, "spec", "http")
+	type target struct {
+		path        string
+		serviceName string
+		servicePort int32
+	}
+	var targets []target
+	for _, item := range routes {
+		route, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		serviceName, servicePort := virtualServiceDestination(route, namespace)
+
+		paths := virtualServicePaths(route, func(path, reason string) { skip("", path, reason) })
+		for _, path := range paths {
+			if healthPath, ok := annotatedHealthPath(annotations, serviceName); ok {
+				path = healthPath
+			}
+			targets = append(targets, target{path, serviceName, servicePort})
+		}
+	}
+
+	var endpoints []Endpoint
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		for _, target := range targets {
+			url := scheme + "://" + host
+			if seen[url+target.path] {
+				continue
+			}
+			seen[url+target.path] = true
+
+			endpoint := Endpoint{
+				Source:      SourceVirtualService,
+				Namespace:   namespace,
+				ServiceName: target.serviceName,
+				ServicePort: target.servicePort,
+				IngressName: name,
+				URL:         url,
+				Path:        target.path,
+				Labels:      virtualService.GetLabels(),
+				Annotations: annotations,
+			}
+			endpoint.ProbeMode, endpoint.ProbeAddress = resourceProbe(probe, "")
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, skipped
+}
+
+// boundToGateway reports whether a VirtualService with the gateways is exposed by
+// a gateway. Without gateways, it only applies to the mesh.
+func boundToGateway(gateways []string) bool {
+	for _, gateway := range gateways {
+		if gateway != meshGateway {
+			return true
+		}
+	}
+	return false
+}
+
+// virtualServicePaths returns the paths probed for the URI matches of an HTTP
+// route, and reports the matches no path can be derived from. A route without
+// URI matches serves every path.
+func virtualServicePaths(route map[string]interface{}, skip func(path, reason string)) []string {
+	matches, _, _ := unstructured.NestedSlice(route, "match")
+	if len(matches) == 0 {
+		return []string{"/"}
+	}
+
+	var paths []string
+	for _, item := range matches {
+		match, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		uri, found, _ := unstructured.NestedMap(match, "uri")
+		if !found {
+			paths = append(paths, "/")
+			continue
+		}
+		// Other fields such as ignoreUriCase do not affect the path
+		prefix, _ := uri["prefix"].(string)
+		exact, _ := uri["exact"].(string)
+		regex, _ := uri["regex"].(string)
+		switch {
+		case prefix != "":
+			paths = append(paths, prefix)
+		case exact != "":
+			paths = append(paths, exact)
+		case regex != "":
+			path, err := regexProbePath(regex)
+			if err != nil {
+				skip(regex, err.Error())
+				continue
+			}
+			paths = append(paths, path)
+		default:
+			skip("", fmt.Sprintf("URI match %v is not supported", uri))
+		}
+	}
+	return paths
+}
+
+// virtualServiceDestination returns the Service and port of the first destination
+// of an HTTP route, when it is a Service of the namespace
+func virtualServiceDestination(route map[string]interface{}, namespace string) (string, int32) {
+	destinations, _, _ := unstructured.NestedSlice(route, "route")
+	if len(destinations) == 0 {
+		return "", 0
+	}
+	destination, ok := destinations[0].(map[string]interface{})
+	if !ok {
+		return "", 0
+	}
+	host, _, _ := unstructured.NestedString(destination, "destination", "host")
+	port, _, _ := unstructured.NestedInt64(destination, "destination", "port", "number")
+
+	// Destinations are short names or FQDNs such as reviews.shop.svc.cluster.local
+	labels := strings.Split(host, ".")
+	switch {
+	case len(labels) == 1:
+		return host, int32(port)
+	case len(labels) >= 3 && labels[1] == namespace && labels[2] == "svc":
+		return labels[0], int32(port)
+	default:
+		return "", 0
+	}
+}
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestVirtualService(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "VirtualService",
+		"metadata": map[string]interface{}{
+			"namespace": "shop",
+			"name":      "storefront",
+		},
+		"spec": spec,
+	}}
+}
+
+func TestExtractEndpointsFromVirtualService(t *testing.T) {
+	virtualService := newTestVirtualService(map[string]interface{}{
+		"hosts":    []interface{}{"shop.example.com", "reviews", "*"},
+		"gateways": []interface{}{"istio-system/public", "mesh"},
+		"http": []interface{}{
+			map[string]interface{}{
+				"match": []interface{}{
+					map[string]interface{}{"uri": map[string]interface{}{"prefix": "/api", "ignoreUriCase": true}},
+					map[string]interface{}{"uri": map[string]interface{}{"exact": "/login"}},
+					map[string]interface{}{"uri": map[string]interface{}{"regex": "/user/[0-9]+"}},
+				},
+				"route": []interface{}{
+					map[string]interface{}{"destination": map[string]interface{}{
+						"host": "api.shop.svc.cluster.local",
+						"port": map[string]interface{}{"number": int64(8080)},
+					}},
+				},
+			},
+			map[string]interface{}{
+				"route": []interface{}{
+					map[string]interface{}{"destination": map[string]interface{}{"host": "web"}},
+				},
+			},
+		},
+	})
+
+	endpoints, skipped := extractEndpointsFromVirtualService(virtualService, ProbeOptions{})
+	want := []string{"http://shop.example.com/api", "http://shop.example.com/login", "http://shop.example.com/"}
+	if len(endpoints) != len(want) {
+		t.Fatalf("Expected %d endpoints, got %+v", len(want), endpoints)
+	}
+	for i, url := range want {
+		if got := endpoints[i].URL + endpoints[i].Path; got != url {
+			t.Errorf("Expected endpoint %d at %s, got %s", i, url, got)
+		}
+		if endpoints[i].Source != SourceVirtualService || endpoints[i].IngressName != "storefront" {
+			t.Errorf("Expected the storefront VirtualService source, got %+v", endpoints[i])
+		}
+	}
+	if endpoints[0].ServiceName != "api" || endpoints[0].ServicePort != 8080 {
+		t.Errorf("Expected Service api:8080, got %s:%d", endpoints[0].ServiceName, endpoints[0].ServicePort)
+	}
+	if endpoints[2].ServiceName != "web" {
+		t.Errorf("Expected Service web, got %q", endpoints[2].ServiceName)
+	}
+
+	// The short and catch-all hosts and the regex path are skipped
+	if len(skipped) != 3 {
+		t.Errorf("Expected 3 skipped rules, got %+v", skipped)
+	}
+}
+
+func TestExtractEndpointsFromVirtualServiceMeshOnly(t *testing.T) {
+	for _, gateways := range [][]interface{}{nil, {"mesh"}} {
+		spec := map[string]interface{}{
+			"hosts": []interface{}{"shop.example.com"},
+			"http":  []interface{}{map[string]interface{}{}},
+		}
+		if gateways != nil {
+			spec["gateways"] = gateways
+		}
+		if endpoints, skipped := extractEndpointsFromVirtualService(newTestVirtualService(spec), ProbeOptions{}); len(endpoints)+len(skipped) != 0 {
+			t.Errorf("Expected mesh-only VirtualServices with gateways %v to be ignored, got %+v and %+v", gateways, endpoints, skipped)
+		}
+	}
+}
+
+func TestExtractEndpointsFromVirtualServiceAnnotations(t *testing.T) {
+	virtualService := newTestVirtualService(map[string]interface{}{
+		"hosts":    []interface{}{"*.example.com"},
+		"gateways": []interface{}{"public"},
+		"http":     []interface{}{map[string]interface{}{}},
+	})
+	virtualService.SetAnnotations(map[string]string{
+		AnnotationWildcardHost:    "www.example.com",
+		AnnotationScheme:          "https",
+		"health.monitor/endpoint": "/healthz",
+	})
+
+	endpoints, _ := extractEndpointsFromVirtualService(virtualService, ProbeOptions{Mode: ProbeModeController, ControllerAddress: "istio-ingressgateway.istio-system"})
+	if len(endpoints) != 1 || endpoints[0].URL+endpoints[0].Path != "https://www.example.com/healthz" {
+		t.Fatalf("Expected https://www.example.com/healthz, got %+v", endpoints)
+	}
+	if endpoints[0].ProbeMode != ProbeModeController || endpoints[0].ProbeAddress != "istio-ingressgateway.istio-system" {
+		t.Errorf("Expected the controller probe mode, got %q at %q", endpoints[0].ProbeMode, endpoints[0].ProbeAddress)
+	}
+}
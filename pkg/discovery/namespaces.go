@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// namespaceMatcher matches namespace names against a list of patterns. A pattern
+// is an exact name, a glob such as "team-*-prod", or a regular expression between
+// slashes such as "/^team-[a-z]+-prod$/".
+type namespaceMatcher struct {
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+// compileNamespacePatterns compiles the patterns, reporting every invalid one
+func compileNamespacePatterns(patterns []string) (*namespaceMatcher, error) {
+	m := &namespaceMatcher{}
+	var errs []error
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid namespace regex %q: %w", pattern, err))
+				continue
+			}
+			m.regexps = append(m.regexps, re)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid namespace glob %q: %w", pattern, err))
+			continue
+		}
+		m.globs = append(m.globs, pattern)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ValidateNamespacePatterns checks that every namespace pattern can be compiled
+func ValidateNamespacePatterns(patterns []string) error {
+	_, err := compileNamespacePatterns(patterns)
+	return err
+}
+
+// empty reports whether the matcher has no patterns
+func (m *namespaceMatcher) empty() bool {
+	return m == nil || len(m.globs)+len(m.regexps) == 0
+}
+
+// matches reports whether the namespace matches any of the patterns
+func (m *namespaceMatcher) matches(namespace string) bool {
+	if m == nil {
+		return false
+	}
+	for _, glob := range m.globs {
+		if ok, _ := path.Match(glob, namespace); ok {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Annotations of the NGINX Ingress controller that make paths regular expressions
+const (
+	nginxUseRegexAnnotation      = "nginx.ingress.kubernetes.io/use-regex"
+	nginxRewriteTargetAnnotation = "nginx.ingress.kubernetes.io/rewrite-target"
+)
+
+// SkippedRule describes an Ingress rule or path, an annotated Service or another
+// routing resource that is not monitored and why
+type SkippedRule struct {
+	Cluster     string `json:"cluster,omitempty"`
+	Source      Source `json:"source,omitempty"`
+	Namespace   string `json:"namespace"`
+	IngressName string `json:"ingress,omitempty"`
+	ServiceName string `json:"service,omitempty"`
+	Host        string `json:"host,omitempty"`
+	Path        string `json:"path,omitempty"`
+	PathType    string `json:"pathType,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// usesRegexPaths reports whether the paths of the Ingress are regular expressions.
+// The NGINX controller implies use-regex when a rewrite target is set.
+func usesRegexPaths(annotations map[string]string) bool {
+	return annotations[nginxUseRegexAnnotation] == "true" || annotations[nginxRewriteTargetAnnotation] != ""
+}
+
+// probePath derives a concrete path that is served by an Ingress path, or returns
+// the reason no such path can be derived
+func probePath(ingressPath networkingv1.HTTPIngressPath, regex bool) (string, error) {
+	routePath := ingressPath.Path
+	if routePath == "" {
+		return "/", nil
+	}
+	if !strings.HasPrefix(routePath, "/") {
+		return "", fmt.Errorf("path %q is not absolute", routePath)
+	}
+
+	pathType := networkingv1.PathTypeImplementationSpecific
+	if ingressPath.PathType != nil {
+		pathType = *ingressPath.PathType
+	}
+
+	switch pathType {
+	case networkingv1.PathTypeExact, networkingv1.PathTypePrefix:
+		// Both match the path itself, unless the controller treats it as a regex
+		if regex {
+			return regexProbePath(routePath)
+		}
+		return routePath, nil
+	case networkingv1.PathTypeImplementationSpecific:
+		if regex {
+			return regexProbePath(routePath)
+		}
+		return globProbePath(routePath), nil
+	default:
+		return "", fmt.Errorf("path type %q is unknown", pathType)
+	}
+}
+
+// regexProbePath derives a path matched by a regular expression path, which the
+// NGINX controller matches case-insensitively against the start of the request path
+func regexProbePath(routePath string) (string, error) {
+	re, err := regexp.Compile("(?i)^(?:" + routePath + ")")
+	if err != nil {
+		return "", fmt.Errorf("path %q is not a valid regular expression: %v", routePath, err)
+	}
+
+	// Try the literal prefix of the expression, e.g. /api for /api(/|$)(.*)
+	var prefix string
+	if literal, err := regexp.Compile(routePath); err == nil {
+		prefix, _ = literal.LiteralPrefix()
+	}
+	for _, candidate := range []string{prefix, strings.TrimSuffix(prefix, "/") + "/"} {
+		if strings.HasPrefix(candidate, "/") && re.MatchString(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no concrete path can be derived from the regular expression %q", routePath)
+}
+
+// globProbePath derives a path from an implementation-specific path, where several
+// controllers treat a trailing * as a wildcard, e.g. /static/* or /*
+func globProbePath(routePath string) string {
+	if i := strings.Index(routePath, "*"); i >= 0 {
+		routePath = routePath[:i]
+	}
+	if routePath == "" {
+		return "/"
+	}
+	return routePath
+}
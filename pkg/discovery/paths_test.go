@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProbePath(t *testing.T) {
+	exact := networkingv1.PathTypeExact
+	prefix := networkingv1.PathTypePrefix
+	specific := networkingv1.PathTypeImplementationSpecific
+	unknown := networkingv1.PathType("Regex")
+
+	tests := []struct {
+		name     string
+		path     string
+		pathType *networkingv1.PathType
+		regex    bool
+		expected string
+		wantErr  bool
+	}{
+		{"exact", "/healthz", &exact, false, "/healthz", false},
+		{"prefix", "/api", &prefix, false, "/api", false},
+		{"empty", "", &prefix, false, "/", false},
+		{"nil path type", "/api", nil, false, "/api", false},
+		{"glob root", "/*", &specific, false, "/", false},
+		{"glob", "/static/*", &specific, false, "/static/", false},
+		{"regex capture", "/api(/|$)(.*)", &specific, true, "/api", false},
+		{"regex wildcard", "/.*", &specific, true, "/", false},
+		{"regex trailing slash", "/app/(.+)?", &specific, true, "/app/", false},
+		{"regex without concrete path", "/user/[0-9]+", &specific, true, "", true},
+		{"invalid regex", "/api(", &specific, true, "", true},
+		{"regex prefix type", "/api(/|$)(.*)", &prefix, true, "/api", false},
+		{"regex exact type", "/health/?", &exact, true, "/health", false},
+		{"regex literal prefix type", "/api", &prefix, true, "/api", false},
+		{"relative", "api", &prefix, false, "", true},
+		{"unknown path type", "/api", &unknown, false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := probePath(networkingv1.HTTPIngressPath{Path: tt.path, PathType: tt.pathType}, tt.regex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("probePath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if path != tt.expected {
+				t.Errorf("probePath(%q) = %q, expected %q", tt.path, path, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractEndpointsFromIngressRegexPaths(t *testing.T) {
+	specific := networkingv1.PathTypeImplementationSpecific
+	ingress := *newTestIngress("default", "web", "web.example.com", nil)
+	ingress.Annotations = map[string]string{nginxRewriteTargetAnnotation: "/$2"}
+	ingress.Spec.Rules[0].HTTP.Paths = []networkingv1.HTTPIngressPath{
+		{Path: "/api(/|$)(.*)", PathType: &specific, Backend: ingress.Spec.Rules[0].HTTP.Paths[0].Backend},
+		{Path: "/user/[0-9]+", PathType: &specific, Backend: ingress.Spec.Rules[0].HTTP.Paths[0].Backend},
+	}
+
+	endpoints, skipped := extractEndpointsFromIngress(ingress)
+	if len(endpoints) != 1 || endpoints[0].Path != "/api" {
+		t.Errorf("Expected one endpoint probing /api, got %+v", endpoints)
+	}
+	if len(skipped) != 1 || skipped[0].Path != "/user/[0-9]+" || skipped[0].PathType != "ImplementationSpecific" {
+		t.Errorf("Expected the regex path without a concrete path to be skipped, got %+v", skipped)
+	}
+
+	// A health annotation replaces the derived path, so nothing is skipped
+	ingress.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{
+		nginxUseRegexAnnotation:   "true",
+		"health.monitor/endpoint": "/healthz",
+	}}
+	if endpoints, skipped := extractEndpointsFromIngress(ingress); len(endpoints) != 2 || len(skipped) != 0 {
+		t.Errorf("Expected both paths to be probed at the health path, got %+v and %+v", endpoints, skipped)
+	}
+}
@@ -0,0 +1,228 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+)
+
+// ResourceSources enables discovering endpoints from routing resources other than
+// Ingresses. A source is only watched when the cluster serves its API, so enabling
+// it in clusters without the resource is harmless.
+type ResourceSources struct {
+	Routes          bool // OpenShift route.openshift.io/v1 Routes
+	VirtualServices bool // Istio networking.istio.io/v1beta1 VirtualServices bound to gateways
+	IngressRoutes   bool // Traefik traefik.io/v1alpha1 IngressRoutes
+}
+
+// resourceDetectionRetry is the delay before retrying to detect a resource API
+// after the discovery request failed
+const resourceDetectionRetry = 30 * time.Second
+
+// resourceSource discovers endpoints from a resource watched through the dynamic
+// client, once the API of the resource has been found in the cluster
+type resourceSource struct {
+	source   Source
+	resource schema.GroupVersionResource
+	extract  func(obj *unstructured.Unstructured, probe ProbeOptions) ([]Endpoint, []SkippedRule)
+
+	mu       sync.RWMutex
+	detected bool                // Whether the presence of the API is known
+	lister   cache.GenericLister // Set while the resource is watched
+	synced   cache.InformerSynced
+	stop     context.CancelFunc // Stops the informer
+}
+
+// hasSynced reports whether the source is known to be absent or disabled, or its
+// cache has synced
+func (s *resourceSource) hasSynced() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.detected && (s.synced == nil || s.synced())
+}
+
+// syncedLister returns the lister of the resource once its cache has synced, or
+// nil when the resource is not watched or still being listed
+func (s *resourceSource) syncedLister() cache.GenericLister {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lister == nil || !s.synced() {
+		return nil
+	}
+	return s.lister
+}
+
+// disable stops watching the resource
+func (s *resourceSource) disable() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		s.stop()
+	}
+	s.lister, s.synced, s.stop = nil, nil, nil
+}
+
+// watchResources registers the enabled resource sources. They are only watched
+// once Start has found their API, and their caches are not waited for: Ingresses
+// are discovered while the sources are detected and listed, so that a missing or
+// forbidden resource never holds up the others.
+func (c *Client) watchResources(sources ResourceSources) {
+	if sources.Routes {
+		c.resources = append(c.resources, &resourceSource{
+			source:   SourceRoute,
+			resource: routeResource,
+			extract:  extractEndpointsFromRoute,
+		})
+	}
+	if sources.VirtualServices {
+		c.resources = append(c.resources, &resourceSource{
+			source:   SourceVirtualService,
+			resource: virtualServiceResource,
+			extract:  extractEndpointsFromVirtualService,
+		})
+	}
+	if sources.IngressRoutes {
+		c.resources = append(c.resources, &resourceSource{
+			source:   SourceIngressRoute,
+			resource: ingressRouteResource,
+			extract:  extractEndpointsFromIngressRoute,
+		})
+	}
+}
+
+// startResource watches the resource when its API is served by the cluster, and
+// disables the source otherwise. Failed discovery requests are retried until the
+// context is cancelled, except when they are forbidden.
+func (c *Client) startResource(ctx context.Context, source *resourceSource) {
+	for {
+		served, err := c.servesResource(source.resource)
+		if err == nil {
+			if served {
+				c.watchResource(ctx, source)
+			} else {
+				logging.Infof("Disabling %s discovery in cluster %q: %s is not served", source.source, c.cluster, source.resource.GroupVersion())
+			}
+			source.mu.Lock()
+			source.detected = true
+			source.mu.Unlock()
+			return
+		}
+
+		logging.Warnf("Failed to detect %s in cluster %q, retrying in %v: %v", source.resource.GroupVersion(), c.cluster, resourceDetectionRetry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resourceDetectionRetry):
+		}
+	}
+}
+
+// servesResource reports whether the cluster serves the resource
+func (c *Client) servesResource(resource schema.GroupVersionResource) (bool, error) {
+	if c.dynamicClient == nil {
+		return false, nil
+	}
+	resources, err := c.clientset.Discovery().ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, served := range resources.APIResources {
+		if served.Name == resource.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// watchResource starts an informer on the resource. The source is disabled when
+// the resource cannot be listed because it is gone or not readable by the monitor,
+// which would otherwise be retried forever.
+func (c *Client) watchResource(ctx context.Context, source *resourceSource) {
+	ctx, stop := context.WithCancel(ctx)
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamicClient, 0)
+	informer := factory.ForResource(source.resource)
+	err := informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			logging.Warnf("Disabling %s discovery in cluster %q: %v", source.source, c.cluster, err)
+			source.disable()
+			return
+		}
+		cache.DefaultWatchErrorHandler(r, err)
+	})
+	if err != nil {
+		logging.Errorf("Error watching %s in cluster %q: %v", source.resource.GroupResource(), c.cluster, err)
+	}
+
+	source.mu.Lock()
+	source.lister = informer.Lister()
+	source.synced = informer.Informer().HasSynced
+	source.stop = stop
+	source.mu.Unlock()
+
+	factory.Start(ctx.Done())
+	logging.Infof("Watching %s in cluster %q", source.resource.GroupResource(), c.cluster)
+}
+
+// resourceEndpoints returns the endpoints of all selected resources of the watched
+// sources and the rules that cannot be probed. Sources that have not synced yet
+// are skipped until they have.
+func (c *Client) resourceEndpoints() ([]Endpoint, []SkippedRule) {
+	var endpoints []Endpoint
+	var skipped []SkippedRule
+	for _, source := range c.resources {
+		lister := source.syncedLister()
+		if lister == nil {
+			if !source.hasSynced() {
+				logging.Debugf("Skipping %s in cluster %q until it has been listed", source.resource.GroupResource(), c.cluster)
+			}
+			continue
+		}
+		objects, err := lister.List(labels.Everything())
+		if err != nil {
+			logging.Warnf("Error listing %s in cluster %q: %v", source.resource.GroupResource(), c.cluster, err)
+			continue
+		}
+
+		for _, object := range objects {
+			resource, ok := object.(*unstructured.Unstructured)
+			if !ok || !c.namespaceSelected(resource.GetNamespace()) {
+				continue
+			}
+			resourceEndpoints, resourceSkipped := source.extract(resource, c.probe)
+			for i := range resourceEndpoints {
+				resourceEndpoints[i].Cluster = c.cluster
+			}
+			for i := range resourceSkipped {
+				resourceSkipped[i].Cluster = c.cluster
+			}
+			endpoints = append(endpoints, resourceEndpoints...)
+			skipped = append(skipped, resourceSkipped...)
+		}
+	}
+	return endpoints, skipped
+}
+
+// resourceProbe selects the address the endpoint of a resource is probed at, from
+// the probe options and the address of the router serving the resource, if known
+func resourceProbe(probe ProbeOptions, routerAddress string) (ProbeMode, string) {
+	switch {
+	case probe.Mode == ProbeModeController && probe.ControllerAddress != "":
+		return ProbeModeController, probe.ControllerAddress
+	case probe.Mode == ProbeModeLoadBalancer && routerAddress != "":
+		return ProbeModeLoadBalancer, routerAddress
+	default:
+		return ProbeModeDNS, ""
+	}
+}
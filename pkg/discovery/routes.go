@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SourceRoute endpoints are OpenShift Routes
+const SourceRoute Source = "route"
+
+// routeResource is the OpenShift Route API
+var routeResource = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+
+// extractEndpointsFromRoute builds the endpoint of an OpenShift Route from its host,
+// path, TLS termination and target Service. The health annotations of Ingresses
+// apply to Routes as well.
+func extractEndpointsFromRoute(route *unstructured.Unstructured, probe ProbeOptions) ([]Endpoint, []SkippedRule) {
+	namespace := route.GetNamespace()
+	name := route.GetName()
+	annotations := route.GetAnnotations()
+
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	routePath, _, _ := unstructured.NestedString(route.Object, "spec", "path")
+	skip := func(reason string) []SkippedRule {
+		return []SkippedRule{{
+			Source:      SourceRoute,
+			Namespace:   namespace,
+			IngressName: name,
+			Host:        host,
+			Path:        routePath,
+			Reason:      reason,
+		}}
+	}
+
+	if host == "" {
+		return nil, skip("route has no host")
+	}
+	kind, _, _ := unstructured.NestedString(route.Object, "spec", "to", "kind")
+	serviceName, _, _ := unstructured.NestedString(route.Object, "spec", "to", "name")
+	if (kind != "" && kind != "Service") || serviceName == "" {
+		return nil, skip("route target is not a service")
+	}
+
+	// Edge, passthrough and reencrypt termination all serve https
+	termination, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "termination")
+	scheme := "http"
+	if termination != "" {
+		scheme = "https"
+	}
+	if annotated := annotatedScheme(annotations); annotated != "" {
+		scheme = annotated
+	}
+
+	// Routes match their path as a prefix, and passthrough routes ignore it
+	healthEndpoint, ok := annotatedHealthPath(annotations, serviceName)
+	switch {
+	case ok:
+	case routePath == "" || strings.EqualFold(termination, "passthrough"):
+		healthEndpoint = "/"
+	case !strings.HasPrefix(routePath, "/"):
+		return nil, skip(fmt.Sprintf("path %q is not absolute", routePath))
+	default:
+		healthEndpoint = routePath
+	}
+
+	endpoint := Endpoint{
+		Source:      SourceRoute,
+		Namespace:   namespace,
+		ServiceName: serviceName,
+		IngressName: name,
+		URL:         scheme + "://" + host,
+		Path:        healthEndpoint,
+		Labels:      route.GetLabels(),
+		Annotations: annotations,
+	}
+
+	// The target port is a Service port name, or the number of the pod port the
+	// Service forwards to
+	if targetPort, found, _ := unstructured.NestedFieldNoCopy(route.Object, "spec", "port", "targetPort"); found {
+		switch port := targetPort.(type) {
+		case string:
+			endpoint.ServicePortName = port
+		case int64:
+			endpoint.TargetPort = int32(port)
+		case float64:
+			endpoint.TargetPort = int32(port)
+		}
+	}
+
+	endpoint.ProbeMode, endpoint.ProbeAddress = resourceProbe(probe, routerAddress(route))
+	return []Endpoint{endpoint}, nil
+}
+
+// routerAddress returns the canonical hostname of the first router in the Route
+// status, if any
+func routerAddress(route *unstructured.Unstructured) string {
+	ingresses, _, _ := unstructured.NestedSlice(route.Object, "status", "ingress")
+	for _, ingress := range ingresses {
+		status, ok := ingress.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if address, _, _ := unstructured.NestedString(status, "routerCanonicalHostname"); address != "" {
+			return address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,252 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestRoute(namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "route.openshift.io/v1",
+		"kind":       "Route",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+		"spec": spec,
+	}}
+}
+
+func TestExtractEndpointsFromRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        map[string]interface{}
+		annotations map[string]string
+		wantURL     string
+		wantPath    string
+		wantSkipped bool
+	}{
+		{
+			name:     "plain http",
+			spec:     map[string]interface{}{"host": "shop.apps.example.com", "to": map[string]interface{}{"kind": "Service", "name": "shop"}},
+			wantURL:  "http://shop.apps.example.com",
+			wantPath: "/",
+		},
+		{
+			name: "edge termination with path",
+			spec: map[string]interface{}{
+				"host": "shop.apps.example.com", "path": "/api",
+				"to":  map[string]interface{}{"kind": "Service", "name": "shop"},
+				"tls": map[string]interface{}{"termination": "edge"},
+			},
+			wantURL:  "https://shop.apps.example.com",
+			wantPath: "/api",
+		},
+		{
+			name: "passthrough ignores the path",
+			spec: map[string]interface{}{
+				"host": "shop.apps.example.com", "path": "/api",
+				"to":  map[string]interface{}{"kind": "Service", "name": "shop"},
+				"tls": map[string]interface{}{"termination": "passthrough"},
+			},
+			wantURL:  "https://shop.apps.example.com",
+			wantPath: "/",
+		},
+		{
+			name: "health annotations",
+			spec: map[string]interface{}{
+				"host": "shop.apps.example.com",
+				"to":   map[string]interface{}{"kind": "Service", "name": "shop"},
+				"tls":  map[string]interface{}{"termination": "reencrypt"},
+			},
+			annotations: map[string]string{"health.monitor/path.shop": "/healthz", AnnotationScheme: "http"},
+			wantURL:     "http://shop.apps.example.com",
+			wantPath:    "/healthz",
+		},
+		{
+			name:        "no host",
+			spec:        map[string]interface{}{"to": map[string]interface{}{"kind": "Service", "name": "shop"}},
+			wantSkipped: true,
+		},
+		{
+			name:        "relative path",
+			spec:        map[string]interface{}{"host": "shop.apps.example.com", "path": "api", "to": map[string]interface{}{"kind": "Service", "name": "shop"}},
+			wantSkipped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute("shop", "storefront", tt.spec)
+			route.SetAnnotations(tt.annotations)
+
+			endpoints, skipped := extractEndpointsFromRoute(route, ProbeOptions{})
+			if tt.wantSkipped {
+				if len(endpoints) != 0 || len(skipped) != 1 || skipped[0].Source != SourceRoute {
+					t.Fatalf("Expected the Route to be skipped, got %+v and %+v", endpoints, skipped)
+				}
+				return
+			}
+			if len(endpoints) != 1 {
+				t.Fatalf("Expected 1 endpoint, got %+v", endpoints)
+			}
+			endpoint := endpoints[0]
+			if endpoint.URL != tt.wantURL || endpoint.Path != tt.wantPath {
+				t.Errorf("Expected %s%s, got %s%s", tt.wantURL, tt.wantPath, endpoint.URL, endpoint.Path)
+			}
+			if endpoint.Source != SourceRoute || endpoint.ServiceName != "shop" || endpoint.IngressName != "storefront" {
+				t.Errorf("Expected the storefront Route to the shop Service, got %+v", endpoint)
+			}
+		})
+	}
+}
+
+func TestExtractEndpointsFromRouteProbe(t *testing.T) {
+	route := newTestRoute("shop", "storefront", map[string]interface{}{
+		"host": "shop.apps.example.com",
+		"to":   map[string]interface{}{"kind": "Service", "name": "shop"},
+		"port": map[string]interface{}{"targetPort": "8080-tcp"},
+	})
+	route.Object["status"] = map[string]interface{}{
+		"ingress": []interface{}{map[string]interface{}{"routerCanonicalHostname": "router-default.apps.example.com"}},
+	}
+
+	endpoints, _ := extractEndpointsFromRoute(route, ProbeOptions{Mode: ProbeModeLoadBalancer})
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected 1 endpoint, got %+v", endpoints)
+	}
+	if endpoints[0].ProbeMode != ProbeModeLoadBalancer || endpoints[0].ProbeAddress != "router-default.apps.example.com" {
+		t.Errorf("Expected the Route to be probed through its router, got %q at %q", endpoints[0].ProbeMode, endpoints[0].ProbeAddress)
+	}
+	if endpoints[0].ServicePortName != "8080-tcp" {
+		t.Errorf("Expected Service port %q, got %q", "8080-tcp", endpoints[0].ServicePortName)
+	}
+}
+
+// TestRouteTargetPort tests that the numeric target port of a Route, a pod port,
+// resolves to the Service port forwarding to it
+func TestRouteTargetPort(t *testing.T) {
+	route := newTestRoute("default", "web", map[string]interface{}{
+		"host": "web.apps.example.com",
+		"to":   map[string]interface{}{"kind": "Service", "name": "web"},
+		"port": map[string]interface{}{"targetPort": int64(8080)},
+	})
+	endpoints, _ := extractEndpointsFromRoute(route, ProbeOptions{})
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected 1 endpoint, got %+v", endpoints)
+	}
+	if endpoints[0].TargetPort != 8080 || endpoints[0].ServicePort != 0 {
+		t.Errorf("Expected target port 8080 without a Service port, got %+v", endpoints[0])
+	}
+
+	client := NewClientForClientset(fake.NewClientset(backendTestObjects()...), ClusterOptions{
+		Backends: BackendOptions{Enabled: true, Resolution: BackendResolutionClusterIP},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+	base, err := client.ResolveBackend(endpoints[0])
+	if err != nil {
+		t.Fatalf("ResolveBackend() error = %v", err)
+	}
+	if base != "http://10.96.0.10:80" {
+		t.Errorf("Expected the Service port 80 forwarding to 8080, got %s", base)
+	}
+}
+
+func TestDiscoverEndpointsRoutes(t *testing.T) {
+	route := newTestRoute("shop", "storefront", map[string]interface{}{
+		"host": "shop.apps.example.com",
+		"to":   map[string]interface{}{"kind": "Service", "name": "shop"},
+	})
+	servedRoutes := []*metav1.APIResourceList{{
+		GroupVersion: routeResource.GroupVersion().String(),
+		APIResources: []metav1.APIResource{{Name: "routes", Namespaced: true, Kind: "Route"}},
+	}}
+	forbidden := func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(routeResource.GroupResource(), "", errors.New("access denied"))
+	}
+	unavailable := func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("discovery is unavailable")
+	}
+
+	tests := []struct {
+		name          string
+		resources     []*metav1.APIResourceList
+		listReactor   k8stesting.ReactionFunc
+		detectReactor k8stesting.ReactionFunc
+		want          int
+	}{
+		{"API served", servedRoutes, nil, nil, 2},
+		{"API absent", nil, nil, nil, 1},
+		{"list forbidden", servedRoutes, forbidden, nil, 1},
+		{"detection failing", servedRoutes, nil, unavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset(newTestIngress("shop", "web", "shop.example.com", nil))
+			clientset.Resources = tt.resources
+			if tt.detectReactor != nil {
+				clientset.PrependReactor("get", "resource", tt.detectReactor)
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{routeResource: "RouteList"}, route)
+			if tt.listReactor != nil {
+				dynamicClient.PrependReactor("list", "routes", tt.listReactor)
+			}
+			client := NewClientForClientset(clientset, ClusterOptions{Name: "okd", Sources: ResourceSources{Routes: true}})
+			client.dynamicClient = dynamicClient
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client.Start(ctx)
+			if !client.WaitForSync(ctx) {
+				t.Fatal("Caches did not sync")
+			}
+
+			// Ingresses are discovered whether or not the Route source is ready
+			endpoints, err := client.DiscoverEndpoints(ctx)
+			if err != nil {
+				t.Fatalf("DiscoverEndpoints() error = %v", err)
+			}
+			if len(endpoints) == 0 || endpoints[0].Source != SourceIngress {
+				t.Fatalf("Expected the Ingress endpoint, got %+v", endpoints)
+			}
+
+			if tt.detectReactor == nil {
+				syncCtx, cancelSync := context.WithTimeout(ctx, 5*time.Second)
+				defer cancelSync()
+				if !cache.WaitForCacheSync(syncCtx.Done(), client.resources[0].hasSynced) {
+					t.Fatal("Route source did not sync")
+				}
+			}
+
+			endpoints, err = client.DiscoverEndpoints(ctx)
+			if err != nil {
+				t.Fatalf("DiscoverEndpoints() error = %v", err)
+			}
+			if len(endpoints) != tt.want {
+				t.Fatalf("Expected %d endpoints, got %+v", tt.want, endpoints)
+			}
+			for _, endpoint := range endpoints {
+				if endpoint.Source == SourceRoute && (endpoint.URL != "http://shop.apps.example.com" || endpoint.Cluster != "okd") {
+					t.Errorf("Expected the Route endpoint in cluster okd, got %+v", endpoint)
+				}
+			}
+		})
+	}
+}
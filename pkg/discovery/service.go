@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+)
+
+// DefaultClusterDomain is the DNS domain of Services when none is configured
+const DefaultClusterDomain = "cluster.local"
+
+const (
+	// AnnotationPort selects the port, by number or name, an annotated Service is
+	// probed on. The first port of the Service is used when it is not set.
+	AnnotationPort = "health.monitor/port"
+	// AnnotationPath sets the path an annotated Service is probed on, "/" by default
+	AnnotationPath = "health.monitor/path"
+)
+
+// annotationPrefix is shared by every annotation of the monitor
+const annotationPrefix = "health.monitor/"
+
+// ServiceOptions enables discovering Services annotated with health.monitor/*
+// annotations, which are probed without an Ingress
+type ServiceOptions struct {
+	Enabled       bool
+	ClusterDomain string // DNS domain of Services, defaults to DefaultClusterDomain
+}
+
+// watchServices adds the shared Service informer to the client for Service discovery
+func (c *Client) watchServices(opts ServiceOptions) {
+	c.serviceLister = c.watchServiceObjects().Lister()
+	c.clusterDomain = opts.ClusterDomain
+	if c.clusterDomain == "" {
+		c.clusterDomain = DefaultClusterDomain
+	}
+}
+
+// watchServiceObjects returns the Service informer of the client, adding it on first
+// use, so that backend probing and Service discovery share a single watch
+func (c *Client) watchServiceObjects() coreinformers.ServiceInformer {
+	if c.serviceInformer == nil {
+		factory := informers.NewSharedInformerFactory(c.clientset, 0)
+		c.serviceInformer = factory.Core().V1().Services()
+		c.cacheSyncs = append(c.cacheSyncs, c.serviceInformer.Informer().HasSynced)
+		c.factories = append(c.factories, factory)
+	}
+	return c.serviceInformer
+}
+
+// serviceEndpoints returns the endpoints of all selected annotated Services and
+// the Services that cannot be probed
+func (c *Client) serviceEndpoints() ([]Endpoint, []SkippedRule, error) {
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var endpoints []Endpoint
+	var skipped []SkippedRule
+	for _, service := range services {
+		if !annotated(service.Annotations) || !c.namespaceSelected(service.Namespace) {
+			continue
+		}
+		serviceEndpoints, serviceSkipped := endpointsFromService(service, c.clusterDomain)
+		for i := range serviceEndpoints {
+			serviceEndpoints[i].Cluster = c.cluster
+		}
+		for i := range serviceSkipped {
+			serviceSkipped[i].Cluster = c.cluster
+		}
+		endpoints = append(endpoints, serviceEndpoints...)
+		skipped = append(skipped, serviceSkipped...)
+	}
+	return endpoints, skipped, nil
+}
+
+// annotated reports whether any annotation of the monitor is set
+func annotated(annotations map[string]string) bool {
+	for key := range annotations {
+		if strings.HasPrefix(key, annotationPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointsFromService builds the endpoint of an annotated Service at its cluster
+// DNS name, and for LoadBalancer Services one per load balancer address
+func endpointsFromService(service *corev1.Service, clusterDomain string) ([]Endpoint, []SkippedRule) {
+	skip := func(reason string) []SkippedRule {
+		return []SkippedRule{{
+			Source:      SourceService,
+			Namespace:   service.Namespace,
+			ServiceName: service.Name,
+			Reason:      reason,
+		}}
+	}
+
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return nil, skip("service is of type ExternalName")
+	}
+	port, err := annotatedPort(service)
+	if err != nil {
+		return nil, skip(err.Error())
+	}
+
+	path := service.Annotations[AnnotationPath]
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, skip(fmt.Sprintf("path %q is not absolute", path))
+	}
+
+	scheme := backendScheme(port)
+	if annotated := annotatedScheme(service.Annotations); annotated != "" {
+		scheme = annotated
+	}
+
+	newEndpoint := func(host string, mode ProbeMode) Endpoint {
+		return Endpoint{
+			Source:          SourceService,
+			Namespace:       service.Namespace,
+			ServiceName:     service.Name,
+			ServicePort:     port.Port,
+			ServicePortName: port.Name,
+			URL:             scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(port.Port))),
+			Path:            path,
+			ProbeMode:       mode,
+			Labels:          service.Labels,
+			Annotations:     service.Annotations,
+		}
+	}
+
+	endpoints := []Endpoint{
+		newEndpoint(service.Name+"."+service.Namespace+".svc."+clusterDomain, ProbeModeDNS),
+	}
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			address := ingress.IP
+			if address == "" {
+				address = ingress.Hostname
+			}
+			if address != "" {
+				endpoints = append(endpoints, newEndpoint(address, ProbeModeLoadBalancer))
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// annotatedPort returns the Service port selected by the port annotation, by
+// number or name, or the first port of the Service
+func annotatedPort(service *corev1.Service) (corev1.ServicePort, error) {
+	if len(service.Spec.Ports) == 0 {
+		return corev1.ServicePort{}, errors.New("service has no ports")
+	}
+	selected := strings.TrimSpace(service.Annotations[AnnotationPort])
+	if selected == "" {
+		return service.Spec.Ports[0], nil
+	}
+	for _, port := range service.Spec.Ports {
+		if port.Name == selected || strconv.Itoa(int(port.Port)) == selected {
+			return port, nil
+		}
+	}
+	return corev1.ServicePort{}, fmt.Errorf("service has no port %q", selected)
+}
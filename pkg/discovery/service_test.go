@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestService(namespace, name string, annotations map[string]string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: ports,
+		},
+	}
+}
+
+func TestEndpointsFromService(t *testing.T) {
+	ports := []corev1.ServicePort{{Name: "metrics", Port: 9090}, {Name: "https", Port: 8443}}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantURL     string
+		wantPath    string
+		wantSkipped bool
+	}{
+		{"first port", map[string]string{AnnotationPath: "/healthz"}, "http://api.shop.svc.cluster.local:9090", "/healthz", false},
+		{"port by name", map[string]string{AnnotationPort: "https"}, "https://api.shop.svc.cluster.local:8443", "/", false},
+		{"port by number", map[string]string{AnnotationPort: "8443", AnnotationScheme: "http"}, "http://api.shop.svc.cluster.local:8443", "/", false},
+		{"unknown port", map[string]string{AnnotationPort: "grpc"}, "", "", true},
+		{"relative path", map[string]string{AnnotationPath: "healthz"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, skipped := endpointsFromService(newTestService("shop", "api", tt.annotations, ports...), DefaultClusterDomain)
+			if tt.wantSkipped {
+				if len(endpoints) != 0 || len(skipped) != 1 || skipped[0].ServiceName != "api" {
+					t.Fatalf("Expected the Service to be skipped, got %+v and %+v", endpoints, skipped)
+				}
+				return
+			}
+			if len(endpoints) != 1 {
+				t.Fatalf("Expected 1 endpoint, got %+v", endpoints)
+			}
+			if endpoints[0].URL != tt.wantURL || endpoints[0].Path != tt.wantPath {
+				t.Errorf("Expected %s%s, got %s%s", tt.wantURL, tt.wantPath, endpoints[0].URL, endpoints[0].Path)
+			}
+			if endpoints[0].Source != SourceService || endpoints[0].ProbeMode != ProbeModeDNS {
+				t.Errorf("Expected a service source probed through DNS, got %q and %q", endpoints[0].Source, endpoints[0].ProbeMode)
+			}
+		})
+	}
+}
+
+func TestEndpointsFromServiceLoadBalancer(t *testing.T) {
+	service := newTestService("shop", "api", map[string]string{AnnotationPath: "/healthz"}, corev1.ServicePort{Port: 80})
+	service.Spec.Type = corev1.ServiceTypeLoadBalancer
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}, {Hostname: "lb.example.com"}}
+
+	endpoints, _ := endpointsFromService(service, "corp.internal")
+	want := []string{"http://api.shop.svc.corp.internal:80", "http://203.0.113.10:80", "http://lb.example.com:80"}
+	if len(endpoints) != len(want) {
+		t.Fatalf("Expected %d endpoints, got %+v", len(want), endpoints)
+	}
+	for i, url := range want {
+		if endpoints[i].URL != url {
+			t.Errorf("Expected endpoint %d at %s, got %s", i, url, endpoints[i].URL)
+		}
+	}
+	if endpoints[1].ProbeMode != ProbeModeLoadBalancer {
+		t.Errorf("Expected the load balancer endpoint in mode %q, got %q", ProbeModeLoadBalancer, endpoints[1].ProbeMode)
+	}
+
+	service.Spec.Type = corev1.ServiceTypeExternalName
+	if endpoints, skipped := endpointsFromService(service, DefaultClusterDomain); len(endpoints) != 0 || len(skipped) != 1 {
+		t.Errorf("Expected ExternalName Services to be skipped, got %+v and %+v", endpoints, skipped)
+	}
+}
+
+func TestDiscoverEndpointsServices(t *testing.T) {
+	client := NewClientForClientset(fake.NewClientset(
+		newTestIngress("default", "web", "web.example.com", nil),
+		newTestService("default", "api", map[string]string{AnnotationPath: "/healthz"}, corev1.ServicePort{Port: 8080}),
+		newTestService("default", "db", nil, corev1.ServicePort{Port: 5432}),
+		newTestService("kube-system", "dns", map[string]string{AnnotationPort: "53"}, corev1.ServicePort{Port: 53}),
+	), ClusterOptions{Name: "prod-eu", Services: ServiceOptions{Enabled: true}})
+	client.SetNamespaceFilter("deny", []string{"kube-system"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	sources := make(map[Source]string)
+	for _, endpoint := range endpoints {
+		sources[endpoint.Source] += endpoint.URL
+		if endpoint.Cluster != "prod-eu" {
+			t.Errorf("Expected endpoint cluster %q, got %q", "prod-eu", endpoint.Cluster)
+		}
+	}
+	if len(endpoints) != 2 || sources[SourceIngress] != "http://web.example.com" ||
+		sources[SourceService] != "http://api.default.svc.cluster.local:8080" {
+		t.Errorf("Expected the Ingress and the annotated Service, got %+v", endpoints)
+	}
+}
+
+// TestServicesWatchedOnce tests that backend probing and Service discovery share
+// a single Service watch
+func TestServicesWatchedOnce(t *testing.T) {
+	clientset := fake.NewClientset(
+		newTestService("default", "api", map[string]string{AnnotationPath: "/healthz"}, corev1.ServicePort{Port: 8080}),
+	)
+	client := NewClientForClientset(clientset, ClusterOptions{
+		Name:     "test",
+		Backends: BackendOptions{Enabled: true, Pods: true},
+		Services: ServiceOptions{Enabled: true},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+
+	watches := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "watch" && action.GetResource().Resource == "services" {
+			watches++
+		}
+	}
+	if watches != 1 {
+		t.Errorf("Expected 1 Service watch, got %d", watches)
+	}
+
+	endpoints, err := client.DiscoverEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected the annotated Service to be discovered, got %+v", endpoints)
+	}
+	if _, _, err := client.backendService(Endpoint{Namespace: "default", ServiceName: "api", ServicePort: 8080}); err != nil {
+		t.Errorf("Expected the backend Service in the shared cache, got %v", err)
+	}
+}
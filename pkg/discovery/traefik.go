@@ -0,0 +1,202 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SourceIngressRoute endpoints are Traefik IngressRoutes
+const SourceIngressRoute Source = "ingressroute"
+
+// ingressRouteResource is the Traefik IngressRoute API
+var ingressRouteResource = schema.GroupVersionResource{Group: "traefik.io", Version: "v1alpha1", Resource: "ingressroutes"}
+
+var (
+	// traefikMatcher matches the Host() and Path() matchers of a Traefik rule, and
+	// their v2 aliases, e.g. Host(`example.com`) && PathPrefix(`/api`)
+	traefikMatcher = regexp.MustCompile(`\b(Host|HostHeader|PathPrefix|Path)\(([^)]*)\)`)
+	// traefikArgument matches a quoted argument of a matcher
+	traefikArgument = regexp.MustCompile("`([^`]*)`|\"([^\"]*)\"")
+)
+
+// extractEndpointsFromIngressRoute builds an endpoint for every host and path of
+// the rules of a Traefik IngressRoute. Hosts come from the Host() matchers and
+// paths from the PathPrefix() and Path() matchers of each || branch of a rule.
+func extractEndpointsFromIngressRoute(ingressRoute *unstructured.Unstructured, probe ProbeOptions) ([]Endpoint, []SkippedRule) {
+	namespace := ingressRoute.GetNamespace()
+	name := ingressRoute.GetName()
+	annotations := ingressRoute.GetAnnotations()
+
+	var skipped []SkippedRule
+	skip := func(host, path, reason string) {
+		skipped = append(skipped, SkippedRule{
+			Source:      SourceIngressRoute,
+			Namespace:   namespace,
+			IngressName: name,
+			Host:        host,
+			Path:        path,
+			Reason:      reason,
+		})
+	}
+
+	scheme := "http"
+	if _, found, _ := unstructured.NestedMap(ingressRoute.Object, "spec", "tls"); found {
+		scheme = "https"
+	}
+	if annotated := annotatedScheme(annotations); annotated != "" {
+		scheme = annotated
+	}
+
+	var endpoints []Endpoint
+	routes, _, _ := unstructured.NestedSlice(ingressRoute.Object, "spec", "routes")
+	for _, item := range routes {
+		route, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		match, _, _ := unstructured.NestedString(route, "match")
+		serviceName, servicePort, servicePortName := ingressRouteService(route, namespace)
+		for _, branch := range parseTraefikRule(match) {
+			hosts, paths := branch.hosts, branch.paths
+			if len(hosts) == 0 {
+				skip("", "", fmt.Sprintf("rule %q has no Host() matcher", branch.rule))
+				continue
+			}
+			if strings.Contains(branch.rule, "!") {
+				skip(strings.Join(hosts, ","), "", fmt.Sprintf("rule %q negates a matcher", branch.rule))
+				continue
+			}
+			if len(paths) == 0 {
+				paths = []string{"/"}
+			}
+			if healthPath, ok := annotatedHealthPath(annotations, serviceName); ok {
+				paths = []string{healthPath}
+			}
+
+			for _, host := range hosts {
+				concrete, err := concreteHost(annotations, host)
+				if err != nil {
+					skip(host, "", err.Error())
+					continue
+				}
+				for _, path := range paths {
+					if !strings.HasPrefix(path, "/") {
+						skip(host, path, fmt.Sprintf("path %q is not absolute", path))
+						continue
+					}
+					endpoint := Endpoint{
+						Source:          SourceIngressRoute,
+						Namespace:       namespace,
+						ServiceName:     serviceName,
+						ServicePort:     servicePort,
+						ServicePortName: servicePortName,
+						IngressName:     name,
+						URL:             scheme + "://" + concrete,
+						Path:            path,
+						Labels:          ingressRoute.GetLabels(),
+						Annotations:     annotations,
+					}
+					endpoint.ProbeMode, endpoint.ProbeAddress = resourceProbe(probe, "")
+					endpoints = append(endpoints, endpoint)
+				}
+			}
+		}
+	}
+	return endpoints, skipped
+}
+
+// traefikBranch is a branch of a Traefik rule, one of the alternatives joined by ||
+type traefikBranch struct {
+	rule  string
+	hosts []string
+	paths []string
+}
+
+// parseTraefikRule splits a rule into its top-level || branches, and returns the
+// hosts of the Host() matchers and the paths of the PathPrefix() and Path()
+// matchers of each branch, so that hosts are only paired with the paths of their
+// own branch. Matchers of other kinds, such as HostRegexp() or Header(), are ignored.
+func parseTraefikRule(rule string) []traefikBranch {
+	var branches []traefikBranch
+	for _, branchRule := range splitTraefikRule(rule) {
+		branch := traefikBranch{rule: branchRule}
+		for _, matcher := range traefikMatcher.FindAllStringSubmatch(branchRule, -1) {
+			for _, argument := range traefikArgument.FindAllStringSubmatch(matcher[2], -1) {
+				value := argument[1] + argument[2]
+				if value == "" {
+					continue
+				}
+				switch matcher[1] {
+				case "Host", "HostHeader":
+					branch.hosts = append(branch.hosts, value)
+				default:
+					branch.paths = append(branch.paths, value)
+				}
+			}
+		}
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+// splitTraefikRule splits a rule on the || operators outside parentheses and quoted
+// arguments. Alternatives nested in parentheses, such as
+// Host(`a`) && (Path(`/x`) || Path(`/y`)), stay in one branch, where they combine
+// with the matchers around them.
+func splitTraefikRule(rule string) []string {
+	var branches []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range rule {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '`' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == '|' && depth == 0 && strings.HasPrefix(rule[i:], "||"):
+			branches = append(branches, strings.TrimSpace(rule[start:i]))
+			start = i + 2
+		}
+	}
+	return append(branches, strings.TrimSpace(rule[start:]))
+}
+
+// ingressRouteService returns the first Service of a route, when it is a Service
+// of the namespace, and its port by number or name
+func ingressRouteService(route map[string]interface{}, namespace string) (string, int32, string) {
+	services, _, _ := unstructured.NestedSlice(route, "services")
+	if len(services) == 0 {
+		return "", 0, ""
+	}
+	service, ok := services[0].(map[string]interface{})
+	if !ok {
+		return "", 0, ""
+	}
+	kind, _, _ := unstructured.NestedString(service, "kind")
+	serviceNamespace, _, _ := unstructured.NestedString(service, "namespace")
+	if (kind != "" && kind != "Service") || (serviceNamespace != "" && serviceNamespace != namespace) {
+		return "", 0, ""
+	}
+
+	name, _, _ := unstructured.NestedString(service, "name")
+	switch port := service["port"].(type) {
+	case string:
+		return name, 0, port
+	case int64:
+		return name, int32(port), ""
+	case float64:
+		return name, int32(port), ""
+	default:
+		return name, 0, ""
+	}
+}
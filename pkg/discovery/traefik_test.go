@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseTraefikRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want []traefikBranch
+	}{
+		{"Host(`example.com`)", []traefikBranch{
+			{"Host(`example.com`)", []string{"example.com"}, nil},
+		}},
+		{"Host(`example.com`) && PathPrefix(`/api`)", []traefikBranch{
+			{"Host(`example.com`) && PathPrefix(`/api`)", []string{"example.com"}, []string{"/api"}},
+		}},
+		{"Host(`a.example.com`) || Host(`b.example.com`)", []traefikBranch{
+			{"Host(`a.example.com`)", []string{"a.example.com"}, nil},
+			{"Host(`b.example.com`)", []string{"b.example.com"}, nil},
+		}},
+		{"(Host(`a.example.com`) && PathPrefix(`/x`)) || (Host(`b.example.com`) && PathPrefix(`/y`))", []traefikBranch{
+			{"(Host(`a.example.com`) && PathPrefix(`/x`))", []string{"a.example.com"}, []string{"/x"}},
+			{"(Host(`b.example.com`) && PathPrefix(`/y`))", []string{"b.example.com"}, []string{"/y"}},
+		}},
+		// Alternatives nested in parentheses combine with the matchers around them
+		{"Host(`example.com`) && (Path(`/a`) || Path(`/b`))", []traefikBranch{
+			{"Host(`example.com`) && (Path(`/a`) || Path(`/b`))", []string{"example.com"}, []string{"/a", "/b"}},
+		}},
+		{"Host(`example.com`) || PathPrefix(`/api`)", []traefikBranch{
+			{"Host(`example.com`)", []string{"example.com"}, nil},
+			{"PathPrefix(`/api`)", nil, []string{"/api"}},
+		}},
+		{"Host(`a.example.com`, `b.example.com`) && Path(`/login`)", []traefikBranch{
+			{"Host(`a.example.com`, `b.example.com`) && Path(`/login`)", []string{"a.example.com", "b.example.com"}, []string{"/login"}},
+		}},
+		{"HostRegexp(`{sub:[a-z]+}.example.com`) && PathPrefix(\"/static\")", []traefikBranch{
+			{"HostRegexp(`{sub:[a-z]+}.example.com`) && PathPrefix(\"/static\")", nil, []string{"/static"}},
+		}},
+		{"Header(`X-Canary`, `a||b`)", []traefikBranch{
+			{"Header(`X-Canary`, `a||b`)", nil, nil},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if branches := parseTraefikRule(tt.rule); !reflect.DeepEqual(branches, tt.want) {
+				t.Errorf("parseTraefikRule() = %+v, want %+v", branches, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractEndpointsFromIngressRoute(t *testing.T) {
+	ingressRoute := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "traefik.io/v1alpha1",
+		"kind":       "IngressRoute",
+		"metadata": map[string]interface{}{
+			"namespace": "shop",
+			"name":      "storefront",
+		},
+		"spec": map[string]interface{}{
+			"entryPoints": []interface{}{"websecure"},
+			"tls":         map[string]interface{}{},
+			"routes": []interface{}{
+				map[string]interface{}{
+					"kind":  "Rule",
+					"match": "Host(`shop.example.com`) && PathPrefix(`/api`)",
+					"services": []interface{}{
+						map[string]interface{}{"name": "api", "port": int64(8080)},
+					},
+				},
+				map[string]interface{}{
+					"kind":     "Rule",
+					"match":    "Host(`shop.example.com`) && !PathPrefix(`/admin`)",
+					"services": []interface{}{map[string]interface{}{"name": "web", "port": "http"}},
+				},
+				map[string]interface{}{
+					"kind":     "Rule",
+					"match":    "PathPrefix(`/`)",
+					"services": []interface{}{map[string]interface{}{"name": "web", "port": "http"}},
+				},
+			},
+		},
+	}}
+
+	endpoints, skipped := extractEndpointsFromIngressRoute(ingressRoute, ProbeOptions{})
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected 1 endpoint, got %+v", endpoints)
+	}
+	endpoint := endpoints[0]
+	if endpoint.URL+endpoint.Path != "https://shop.example.com/api" {
+		t.Errorf("Expected https://shop.example.com/api, got %s%s", endpoint.URL, endpoint.Path)
+	}
+	if endpoint.Source != SourceIngressRoute || endpoint.ServiceName != "api" || endpoint.ServicePort != 8080 {
+		t.Errorf("Expected the IngressRoute source and Service api:8080, got %+v", endpoint)
+	}
+
+	// The negated and host-less rules are skipped
+	if len(skipped) != 2 || skipped[0].Source != SourceIngressRoute {
+		t.Errorf("Expected 2 skipped rules, got %+v", skipped)
+	}
+}
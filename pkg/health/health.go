@@ -0,0 +1,124 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+)
+
+// Check reports the health of a single component. It returns a short human
+// readable detail, and a non-nil error when the component is unhealthy.
+type Check func() (string, error)
+
+// ComponentStatus is the status of a single component in a probe response
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Response is the JSON body returned by the probe handlers
+type Response struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Status values used in probe responses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker aggregates component checks for the liveness and readiness probes
+type Checker struct {
+	mu        sync.RWMutex // Protects the check lists
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+// NewChecker creates a new health checker with no registered checks
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddLivenessCheck registers a check that must pass for the process to be considered alive
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers a check that must pass for the process to be considered ready
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name: name, check: check})
+}
+
+// Live evaluates the liveness checks
+func (c *Checker) Live() Response {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return evaluate(c.liveness)
+}
+
+// Ready evaluates the readiness checks
+func (c *Checker) Ready() Response {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return evaluate(c.readiness)
+}
+
+// LiveHandler serves the liveness probe
+func (c *Checker) LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, c.Live())
+	}
+}
+
+// ReadyHandler serves the readiness probe
+func (c *Checker) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, c.Ready())
+	}
+}
+
+func evaluate(checks []namedCheck) Response {
+	response := Response{
+		Status:     StatusOK,
+		Components: make(map[string]ComponentStatus, len(checks)),
+	}
+
+	for _, c := range checks {
+		detail, err := c.check()
+		status := ComponentStatus{Status: StatusOK, Detail: detail}
+		if err != nil {
+			status.Status = StatusFail
+			status.Error = err.Error()
+			response.Status = StatusFail
+		}
+		response.Components[c.name] = status
+	}
+
+	return response
+}
+
+func writeResponse(w http.ResponseWriter, response Response) {
+	status := http.StatusOK
+	if response.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.Errorf("Error encoding health response: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckerReady(t *testing.T) {
+	checker := NewChecker()
+	checker.AddReadinessCheck("discovery", func() (string, error) {
+		return "synced", nil
+	})
+	checker.AddReadinessCheck("metrics", func() (string, error) {
+		return "", errors.New("exporter not initialized")
+	})
+
+	response := checker.Ready()
+	if response.Status != StatusFail {
+		t.Errorf("Expected status %s, got %s", StatusFail, response.Status)
+	}
+	if got := response.Components["discovery"]; got.Status != StatusOK || got.Detail != "synced" {
+		t.Errorf("Unexpected discovery status: %+v", got)
+	}
+	if got := response.Components["metrics"]; got.Status != StatusFail || got.Error != "exporter not initialized" {
+		t.Errorf("Unexpected metrics status: %+v", got)
+	}
+}
+
+func TestCheckerNoChecks(t *testing.T) {
+	checker := NewChecker()
+
+	if response := checker.Live(); response.Status != StatusOK {
+		t.Errorf("Expected status %s with no liveness checks, got %s", StatusOK, response.Status)
+	}
+	if response := checker.Ready(); response.Status != StatusOK {
+		t.Errorf("Expected status %s with no readiness checks, got %s", StatusOK, response.Status)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	healthy := true
+	checker := NewChecker()
+	checker.AddLivenessCheck("scheduler", func() (string, error) {
+		if !healthy {
+			return "", errors.New("stalled")
+		}
+		return "", nil
+	})
+
+	tests := []struct {
+		name           string
+		healthy        bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"healthy", true, http.StatusOK, StatusOK},
+		{"unhealthy", false, http.StatusServiceUnavailable, StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy = tt.healthy
+
+			rec := httptest.NewRecorder()
+			checker.LiveHandler()(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			var response Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Status != tt.expectedBody {
+				t.Errorf("Expected status %s, got %s", tt.expectedBody, response.Status)
+			}
+		})
+	}
+}
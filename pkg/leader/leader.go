@@ -0,0 +1,145 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/metrics"
+)
+
+// Config holds the leader election settings
+type Config struct {
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+// Callbacks are invoked when this replica gains or loses leadership
+type Callbacks struct {
+	OnStartedLeading func(ctx context.Context)
+	OnStoppedLeading func()
+}
+
+// Elector runs Lease-based leader election
+type Elector struct {
+	elector         *leaderelection.LeaderElector
+	config          Config
+	metricsProvider *metrics.Provider
+	isLeader        atomic.Bool
+}
+
+// NewElector creates a new leader elector
+func NewElector(client kubernetes.Interface, config Config, metricsProvider *metrics.Provider, callbacks Callbacks) (*Elector, error) {
+	e := &Elector{
+		config:          config,
+		metricsProvider: metricsProvider,
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LeaseName,
+			Namespace: config.LeaseNamespace,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.Identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   config.LeaseDuration,
+		RenewDeadline:   config.RenewDeadline,
+		RetryPeriod:     config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            config.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logging.Infof("Acquired leadership of lease %s/%s as %s", config.LeaseNamespace, config.LeaseName, config.Identity)
+				e.isLeader.Store(true)
+				if callbacks.OnStartedLeading != nil {
+					callbacks.OnStartedLeading(ctx)
+				}
+			},
+			OnStoppedLeading: func() {
+				logging.Infof("Lost leadership of lease %s/%s", config.LeaseNamespace, config.LeaseName)
+				e.isLeader.Store(false)
+				if callbacks.OnStoppedLeading != nil {
+					callbacks.OnStoppedLeading()
+				}
+			},
+			OnNewLeader: func(identity string) {
+				if identity != config.Identity {
+					logging.Infof("Replica %s is the leader, standing by", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader elector: %w", err)
+	}
+	e.elector = elector
+
+	return e, nil
+}
+
+// Run participates in leader election until the context is cancelled. After
+// losing leadership the replica stands by and campaigns again.
+func (e *Elector) Run(ctx context.Context) {
+	if e.metricsProvider != nil {
+		_, err := e.metricsProvider.GetMeter().RegisterCallback(
+			func(ctx context.Context, o metric.Observer) error {
+				value := int64(0)
+				if e.IsLeader() {
+					value = 1
+				}
+				o.ObserveInt64(e.metricsProvider.GetLeaderGauge(), value,
+					metric.WithAttributes(attribute.String("identity", e.config.Identity)))
+				return nil
+			},
+			e.metricsProvider.GetLeaderGauge(),
+		)
+		if err != nil {
+			logging.Errorf("Error registering callback for leader gauge: %v", err)
+		}
+	}
+
+	for {
+		e.elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.config.RetryPeriod):
+		}
+	}
+}
+
+// IsLeader reports whether this replica currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.isLeader.Load()
+}
+
+// Status describes this replica's role for the readiness probe
+func (e *Elector) Status() string {
+	if e.IsLeader() {
+		return "leader"
+	}
+	if leader := e.elector.GetLeader(); leader != "" {
+		return fmt.Sprintf("standby (leader is %s)", leader)
+	}
+	return "standby (no leader elected)"
+}
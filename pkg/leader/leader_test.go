@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestElectorAcquiresLeadership(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+
+	elector, err := NewElector(
+		fake.NewClientset(),
+		Config{
+			LeaseName:      "k8s-http-monitor",
+			LeaseNamespace: "monitoring",
+			Identity:       "replica-a",
+			LeaseDuration:  2 * time.Second,
+			RenewDeadline:  time.Second,
+			RetryPeriod:    100 * time.Millisecond,
+		},
+		nil,
+		Callbacks{
+			OnStartedLeading: func(context.Context) { close(started) },
+			OnStoppedLeading: func() { close(stopped) },
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewElector() error = %v", err)
+	}
+
+	if elector.IsLeader() {
+		t.Errorf("Expected elector not to be leader before running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Elector did not acquire leadership")
+	}
+	if !elector.IsLeader() {
+		t.Errorf("Expected elector to be leader after acquiring the lease")
+	}
+	if status := elector.Status(); status != "leader" {
+		t.Errorf("Expected status %q, got %q", "leader", status)
+	}
+
+	// Cancelling releases the lease and stops leading
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Elector did not stop leading after cancellation")
+	}
+	<-done
+	if elector.IsLeader() {
+		t.Errorf("Expected elector not to be leader after cancellation")
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message
+type Level int32
+
+// Supported log levels, from most to least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses a level name such as "debug" or "warn"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+var currentLevel atomic.Int32
+
+func init() {
+	currentLevel.Store(int32(LevelInfo))
+}
+
+// SetLevel sets the minimum level of messages that are logged
+func SetLevel(level Level) {
+	currentLevel.Store(int32(level))
+}
+
+// Enabled reports whether messages at the given level are logged
+func Enabled(level Level) bool {
+	return level >= Level(currentLevel.Load())
+}
+
+// Debugf logs a verbose message useful when troubleshooting
+func Debugf(format string, args ...interface{}) {
+	logf(LevelDebug, format, args...)
+}
+
+// Infof logs a message about normal operation
+func Infof(format string, args ...interface{}) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a message about an unexpected but recoverable condition
+func Warnf(format string, args ...interface{}) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf logs a message about a failed operation
+func Errorf(format string, args ...interface{}) {
+	logf(LevelError, format, args...)
+}
+
+func logf(level Level, format string, args ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	log.Output(3, strings.ToUpper(level.String())+" "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Level
+		wantErr  bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"verbose", LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if level != tt.expected {
+				t.Errorf("ParseLevel(%q) = %v, expected %v", tt.name, level, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		SetLevel(LevelInfo)
+	}()
+
+	SetLevel(LevelWarn)
+	Debugf("debug %d", 1)
+	Infof("info %d", 2)
+	Warnf("warn %d", 3)
+	Errorf("error %d", 4)
+
+	output := buf.String()
+	if strings.Contains(output, "debug 1") || strings.Contains(output, "info 2") {
+		t.Errorf("Expected messages below warn to be dropped, got %q", output)
+	}
+	if !strings.Contains(output, "WARN warn 3") || !strings.Contains(output, "ERROR error 4") {
+		t.Errorf("Expected warn and error messages with their level, got %q", output)
+	}
+}
@@ -2,15 +2,22 @@ package metrics
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
 )
 
 // Provider manages OpenTelemetry metrics
@@ -20,24 +27,96 @@ type Provider struct {
 	upGauge               metric.Int64ObservableGauge
 	requestCounter        metric.Int64Counter
 	responseTimeHistogram metric.Float64Histogram
+	redirectCounter       metric.Int64Counter
+	backendUpGauge        metric.Int64ObservableGauge
+	backendResponseTime   metric.Float64Histogram
+	ingressOnlyGauge      metric.Int64ObservableGauge
+	podsHealthyGauge      metric.Float64ObservableGauge
+	podsReadyFailingGauge metric.Int64ObservableGauge
+	leaderGauge           metric.Int64ObservableGauge
+	reloadCounter         metric.Int64Counter
+	exporter              *trackingExporter
+	shutdown              atomic.Bool
+}
+
+// trackingExporter records the outcome of the last export of the wrapped exporter,
+// so that readiness reflects whether metrics actually reach the collector
+type trackingExporter struct {
+	sdkmetric.Exporter
+	mu         sync.Mutex // Protects the fields below
+	lastExport time.Time
+	lastErr    error
+}
+
+// Export exports the metrics and records the outcome
+func (e *trackingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastExport = time.Now()
+	e.lastErr = err
+	return err
+}
+
+// last returns the time and error of the last export, or the zero time if none ran yet
+func (e *trackingExporter) last() (time.Time, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastExport, e.lastErr
+}
+
+// Option is a functional option for configuring the metrics provider
+type Option func(*providerOptions)
+
+type providerOptions struct {
+	resourceAttributes []attribute.KeyValue
+}
+
+// WithResourceAttributes adds attributes to the resource describing this monitor instance
+func WithResourceAttributes(attrs ...attribute.KeyValue) Option {
+	return func(o *providerOptions) {
+		o.resourceAttributes = append(o.resourceAttributes, attrs...)
+	}
+}
+
+// WithLocation identifies where this monitor probes from using the standard cloud
+// and Kubernetes resource attributes; empty values are omitted
+func WithLocation(region, zone, cluster string) Option {
+	return func(o *providerOptions) {
+		if region != "" {
+			o.resourceAttributes = append(o.resourceAttributes, semconv.CloudRegion(region))
+		}
+		if zone != "" {
+			o.resourceAttributes = append(o.resourceAttributes, semconv.CloudAvailabilityZone(zone))
+		}
+		if cluster != "" {
+			o.resourceAttributes = append(o.resourceAttributes, semconv.K8SClusterName(cluster))
+		}
+	}
 }
 
 // NewProvider creates a new metrics provider
-func NewProvider(ctx context.Context, otelCollectorURL string, metricsInterval time.Duration) (*Provider, error) {
+func NewProvider(ctx context.Context, otelCollectorURL string, metricsInterval time.Duration, options ...Option) (*Provider, error) {
+	// Apply options
+	opts := &providerOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
 	// Create OTLP exporter
-	exporter, err := otlpmetricgrpc.New(ctx,
+	otlpExporter, err := otlpmetricgrpc.New(ctx,
 		otlpmetricgrpc.WithEndpoint(otelCollectorURL),
 		otlpmetricgrpc.WithInsecure(),
 	)
 	if err != nil {
 		return nil, err
 	}
+	exporter := &trackingExporter{Exporter: otlpExporter}
 
 	// Create resource
-	res := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("k8s-endpoint-monitor"),
-	)
+	attrs := append([]attribute.KeyValue{semconv.ServiceName("k8s-endpoint-monitor")}, opts.resourceAttributes...)
+	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
 
 	// Create meter provider
 	meterProvider := sdkmetric.NewMeterProvider(
@@ -77,22 +156,121 @@ func NewProvider(ctx context.Context, otelCollectorURL string, metricsInterval t
 		return nil, err
 	}
 
+	redirectCounter, err := meter.Int64Counter(
+		"http_endpoint_https_redirect_check_count",
+		metric.WithDescription("Number of checks that the http URL of an https endpoint redirects to https"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	backendUpGauge, err := meter.Int64ObservableGauge(
+		"http_backend_up",
+		metric.WithDescription("Indicates if the backend Service of an endpoint is responding when probed directly (1=up, 0=down)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	backendResponseTime, err := meter.Float64Histogram(
+		"http_backend_response_time",
+		metric.WithDescription("Response time of direct backend Service checks"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ingressOnlyGauge, err := meter.Int64ObservableGauge(
+		"http_endpoint_ingress_only_failure",
+		metric.WithDescription("Indicates if an endpoint is down while its backend Service is up (1=ingress-only failure)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	podsHealthyGauge, err := meter.Float64ObservableGauge(
+		"http_backend_pods_healthy_ratio",
+		metric.WithDescription("Ratio of the pods of the backend Service passing the health check to all its pods"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	podsReadyFailingGauge, err := meter.Int64ObservableGauge(
+		"http_backend_pods_ready_failing",
+		metric.WithDescription("Number of ready pods of the backend Service failing the health check"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderGauge, err := meter.Int64ObservableGauge(
+		"http_monitor_leader",
+		metric.WithDescription("Indicates if this replica is the elected leader (1=leader, 0=standby)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadCounter, err := meter.Int64Counter(
+		"http_monitor_config_reloads",
+		metric.WithDescription("Number of configuration reloads by result (success or failure)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Provider{
 		meterProvider:         meterProvider,
 		meter:                 meter,
 		upGauge:               upGauge,
 		requestCounter:        requestCounter,
 		responseTimeHistogram: responseTimeHistogram,
+		redirectCounter:       redirectCounter,
+		backendUpGauge:        backendUpGauge,
+		backendResponseTime:   backendResponseTime,
+		ingressOnlyGauge:      ingressOnlyGauge,
+		podsHealthyGauge:      podsHealthyGauge,
+		podsReadyFailingGauge: podsReadyFailingGauge,
+		leaderGauge:           leaderGauge,
+		reloadCounter:         reloadCounter,
+		exporter:              exporter,
 	}, nil
 }
 
 // Shutdown stops the metric provider
 func (p *Provider) Shutdown(ctx context.Context) {
+	p.shutdown.Store(true)
 	if err := p.meterProvider.Shutdown(ctx); err != nil {
-		log.Printf("Error shutting down meter provider: %v", err)
+		logging.Errorf("Error shutting down meter provider: %v", err)
 	}
 }
 
+// Ready reports whether the provider is exporting metrics. It returns an error
+// when the provider is shut down or the last export failed, and otherwise the time
+// of the last successful export, which is zero before the first one.
+func (p *Provider) Ready() (time.Time, error) {
+	if p.shutdown.Load() {
+		return time.Time{}, errors.New("metrics exporter is shut down")
+	}
+	if p.exporter == nil {
+		return time.Time{}, errors.New("metrics exporter is not initialized")
+	}
+	lastExport, err := p.exporter.last()
+	if err != nil {
+		return lastExport, fmt.Errorf("last metrics export at %s failed: %w", lastExport.Format(time.RFC3339), err)
+	}
+	return lastExport, nil
+}
+
 // GetUpGauge returns the up gauge
 func (p *Provider) GetUpGauge() metric.Int64ObservableGauge {
 	return p.upGauge
@@ -108,6 +286,46 @@ func (p *Provider) GetResponseTimeHistogram() metric.Float64Histogram {
 	return p.responseTimeHistogram
 }
 
+// GetRedirectCounter returns the HTTPS redirect check counter
+func (p *Provider) GetRedirectCounter() metric.Int64Counter {
+	return p.redirectCounter
+}
+
+// GetBackendUpGauge returns the backend up gauge
+func (p *Provider) GetBackendUpGauge() metric.Int64ObservableGauge {
+	return p.backendUpGauge
+}
+
+// GetBackendResponseTimeHistogram returns the backend response time histogram
+func (p *Provider) GetBackendResponseTimeHistogram() metric.Float64Histogram {
+	return p.backendResponseTime
+}
+
+// GetIngressOnlyFailureGauge returns the ingress-only failure gauge
+func (p *Provider) GetIngressOnlyFailureGauge() metric.Int64ObservableGauge {
+	return p.ingressOnlyGauge
+}
+
+// GetPodsHealthyRatioGauge returns the healthy pods ratio gauge
+func (p *Provider) GetPodsHealthyRatioGauge() metric.Float64ObservableGauge {
+	return p.podsHealthyGauge
+}
+
+// GetPodsReadyFailingGauge returns the gauge of ready pods failing the health check
+func (p *Provider) GetPodsReadyFailingGauge() metric.Int64ObservableGauge {
+	return p.podsReadyFailingGauge
+}
+
+// GetLeaderGauge returns the leader election gauge
+func (p *Provider) GetLeaderGauge() metric.Int64ObservableGauge {
+	return p.leaderGauge
+}
+
+// GetReloadCounter returns the configuration reload counter
+func (p *Provider) GetReloadCounter() metric.Int64Counter {
+	return p.reloadCounter
+}
+
 // GetMeter returns the meter
 func (p *Provider) GetMeter() metric.Meter {
 	return p.meter
@@ -2,7 +2,11 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 // mockExporter is a mock implementation of the OpenTelemetry exporter
@@ -36,3 +40,39 @@ func TestProviderShutdown(t *testing.T) {
 func TestMetricsIntegration(t *testing.T) {
 	t.Skip("Skipping integration test")
 }
+
+// stubExporter returns the configured error from every export
+type stubExporter struct {
+	sdkmetric.Exporter
+	err error
+}
+
+func (e *stubExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.err
+}
+
+func TestProviderReady(t *testing.T) {
+	stub := &stubExporter{}
+	p := &Provider{exporter: &trackingExporter{Exporter: stub}}
+
+	if lastExport, err := p.Ready(); err != nil || !lastExport.IsZero() {
+		t.Errorf("Expected a ready provider without exports, got %v, %v", lastExport, err)
+	}
+
+	// A failed export makes the provider unready until an export succeeds
+	stub.err = errors.New("connection refused")
+	p.exporter.Export(context.Background(), &metricdata.ResourceMetrics{})
+	if _, err := p.Ready(); err == nil {
+		t.Errorf("Expected an error after a failed export")
+	}
+	stub.err = nil
+	p.exporter.Export(context.Background(), &metricdata.ResourceMetrics{})
+	if lastExport, err := p.Ready(); err != nil || lastExport.IsZero() {
+		t.Errorf("Expected the time of the last export, got %v, %v", lastExport, err)
+	}
+
+	p.shutdown.Store(true)
+	if _, err := p.Ready(); err == nil {
+		t.Errorf("Expected an error once shut down")
+	}
+}
@@ -0,0 +1,142 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+)
+
+// BackendResult holds the outcome of probing the backend Service of an endpoint directly
+type BackendResult struct {
+	URL            string  `json:"url,omitempty"`
+	Up             bool    `json:"up"`
+	StatusCode     int     `json:"statusCode,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	ResponseTimeMs float64 `json:"responseTimeMs"`
+}
+
+// PodResult holds the outcome of probing one pod of the backend Service directly.
+// Pods that are not ready are reported without being probed.
+type PodResult struct {
+	Pod            string  `json:"pod"`
+	URL            string  `json:"url"`
+	Ready          bool    `json:"ready"`
+	Terminating    bool    `json:"terminating,omitempty"`
+	Up             bool    `json:"up"`
+	StatusCode     int     `json:"statusCode,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	ResponseTimeMs float64 `json:"responseTimeMs,omitempty"`
+}
+
+// clusterClient returns the discovery client of the cluster, or nil if unknown
+func (m *Monitor) clusterClient(cluster string) *discovery.Client {
+	for _, client := range m.discoveryClients {
+		if client.Cluster() == cluster {
+			return client
+		}
+	}
+	return nil
+}
+
+// probeDirect requests the path of the endpoint at a backend base URL, with the
+// Host header of the endpoint
+func (m *Monitor) probeDirect(ctx context.Context, target string, endpoint discovery.Endpoint) (int, float64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if u, err := url.Parse(endpoint.URL); err == nil {
+		req.Host = u.Host
+	}
+
+	startTime := time.Now()
+	resp, err := m.backendClient.Do(req)
+	duration := float64(time.Since(startTime).Milliseconds())
+	if err != nil {
+		return 0, duration, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, duration, nil
+}
+
+// checkBackend probes the backend Service of the endpoint directly on the same
+// path, with the Host header of the endpoint
+func (m *Monitor) checkBackend(ctx context.Context, client *discovery.Client, endpoint discovery.Endpoint) *BackendResult {
+	result := &BackendResult{}
+
+	base, err := client.ResolveBackend(endpoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = base + endpoint.Path
+
+	result.StatusCode, result.ResponseTimeMs, err = m.probeDirect(ctx, result.URL, endpoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Up = m.checkStatus(result.StatusCode)
+	return result
+}
+
+// checkPods probes every ready pod of the backend Service of the endpoint
+// concurrently. A ready pod failing the check is logged, since load balancing
+// across the other pods hides it from the endpoint check.
+func (m *Monitor) checkPods(ctx context.Context, client *discovery.Client, endpoint discovery.Endpoint) ([]PodResult, error) {
+	pods, err := client.ResolvePods(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PodResult, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		results[i] = PodResult{
+			Pod:         pod.Name,
+			URL:         pod.URL + endpoint.Path,
+			Ready:       pod.Ready,
+			Terminating: pod.Terminating,
+		}
+		if !pod.Ready {
+			results[i].Error = "pod is not ready"
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *PodResult) {
+			defer wg.Done()
+			statusCode, duration, err := m.probeDirect(ctx, result.URL, endpoint)
+			result.StatusCode, result.ResponseTimeMs = statusCode, duration
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Up = m.checkStatus(statusCode)
+			}
+			if !result.Up {
+				logging.Warnf("Pod %s/%s is ready but fails the health check of %s%s", endpoint.Namespace, result.Pod, endpoint.URL, endpoint.Path)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// podCounts returns the number of healthy pods and of ready pods failing the check
+func podCounts(pods []PodResult) (healthy, readyFailing int) {
+	for _, pod := range pods {
+		switch {
+		case pod.Up:
+			healthy++
+		case pod.Ready:
+			readyFailing++
+		}
+	}
+	return healthy, readyFailing
+}
@@ -0,0 +1,278 @@
+package monitoring
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/metrics"
+)
+
+// TestProbeDirect tests that backends are probed with the Host header of the endpoint
+func TestProbeDirect(t *testing.T) {
+	var host, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, path = r.Host, r.URL.Path
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	m := NewMonitor(nil, nil)
+	endpoint := discovery.Endpoint{URL: "https://web.example.com", Path: "/healthz"}
+	statusCode, _, err := m.probeDirect(context.Background(), server.URL+endpoint.Path, endpoint)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if statusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, statusCode)
+	}
+	if host != "web.example.com" || path != "/healthz" {
+		t.Errorf("Expected a request for web.example.com/healthz, got %s%s", host, path)
+	}
+}
+
+// TestPodCounts tests the counting of healthy pods and ready pods failing the check
+func TestPodCounts(t *testing.T) {
+	pods := []PodResult{
+		{Pod: "a", Ready: true, Up: true},
+		{Pod: "b", Ready: true, Up: false},
+		{Pod: "c", Ready: false, Up: false},
+		{Pod: "d", Ready: true, Up: true},
+	}
+
+	healthy, readyFailing := podCounts(pods)
+	if healthy != 2 || readyFailing != 1 {
+		t.Errorf("Expected 2 healthy and 1 ready failing pod, got %d and %d", healthy, readyFailing)
+	}
+}
+
+// newTestProvider creates a metrics provider whose exporter never runs in tests
+func newTestProvider(t *testing.T) *metrics.Provider {
+	t.Helper()
+	provider, err := metrics.NewProvider(context.Background(), "127.0.0.1:4317", time.Hour)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	return provider
+}
+
+// newTestCluster creates a synced discovery client of cluster "test" on a fake
+// clientset holding the objects
+func newTestCluster(t *testing.T, backends discovery.BackendOptions, objects ...runtime.Object) *discovery.Client {
+	t.Helper()
+	client := discovery.NewClientForClientset(fake.NewClientset(objects...), discovery.ClusterOptions{Name: "test", Backends: backends})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client.Start(ctx)
+	if !client.WaitForSync(ctx) {
+		t.Fatal("Caches did not sync")
+	}
+	return client
+}
+
+// statusServer serves every request with the status code
+func statusServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serverPort returns the port a test server listens on
+func serverPort(server *httptest.Server) int32 {
+	return int32(server.Listener.Addr().(*net.TCPAddr).Port)
+}
+
+// TestCheckEndpointBackend tests that a failing endpoint with a healthy backend
+// Service is reported as an ingress-only failure
+func TestCheckEndpointBackend(t *testing.T) {
+	tests := []struct {
+		name              string
+		ingressStatus     int
+		backendStatus     int
+		expectUp          bool
+		expectBackendUp   bool
+		expectIngressOnly bool
+	}{
+		{"ingress down, backend up", http.StatusBadGateway, http.StatusOK, false, true, true},
+		{"both down", http.StatusBadGateway, http.StatusInternalServerError, false, false, false},
+		{"both up", http.StatusOK, http.StatusOK, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := statusServer(t, tt.ingressStatus)
+			backend := statusServer(t, tt.backendStatus)
+
+			client := newTestCluster(t, discovery.BackendOptions{Enabled: true, Resolution: discovery.BackendResolutionClusterIP}, &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "127.0.0.1",
+					Ports:     []corev1.ServicePort{{Name: "http", Port: serverPort(backend)}},
+				},
+			})
+			m := NewMonitor([]*discovery.Client{client}, newTestProvider(t), WithSuccessStatusCodes(nil))
+
+			endpoint := discovery.Endpoint{
+				Cluster:         "test",
+				Source:          discovery.SourceIngress,
+				Namespace:       "default",
+				IngressName:     "web",
+				ServiceName:     "web",
+				ServicePortName: "http",
+				URL:             ingress.URL,
+				Path:            "/healthz",
+			}
+			result := m.checkEndpoint(context.Background(), endpoint)
+
+			if result.Up != tt.expectUp {
+				t.Errorf("Expected up = %v, got %+v", tt.expectUp, result)
+			}
+			if result.Backend == nil {
+				t.Fatalf("Expected the backend to be probed")
+			}
+			if result.Backend.Up != tt.expectBackendUp || result.Backend.StatusCode != tt.backendStatus {
+				t.Errorf("Expected backend up = %v with status %d, got %+v", tt.expectBackendUp, tt.backendStatus, result.Backend)
+			}
+			if result.Backend.URL != backend.URL+"/healthz" {
+				t.Errorf("Expected the backend to be probed at %s/healthz, got %s", backend.URL, result.Backend.URL)
+			}
+			if result.IngressOnlyFailure != tt.expectIngressOnly {
+				t.Errorf("Expected ingress-only failure = %v, got %+v", tt.expectIngressOnly, result)
+			}
+			if recorded := m.results[endpointKey(endpoint)]; recorded.IngressOnlyFailure != tt.expectIngressOnly {
+				t.Errorf("Expected the recorded result to match, got %+v", recorded)
+			}
+		})
+	}
+}
+
+// endpointSlice returns an EndpointSlice of the Service with a single pod at 127.0.0.1
+func endpointSlice(service, pod string, port int32, ready bool) *discoveryv1.EndpointSlice {
+	portName := "http"
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  []string{"127.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod},
+		}},
+	}
+}
+
+// recordingObserver records the last value observed for every instrument
+type recordingObserver struct {
+	metric.Observer
+	int64s   map[metric.Int64Observable]int64
+	float64s map[metric.Float64Observable]float64
+}
+
+func (o *recordingObserver) ObserveInt64(instrument metric.Int64Observable, value int64, _ ...metric.ObserveOption) {
+	o.int64s[instrument] = value
+}
+
+func (o *recordingObserver) ObserveFloat64(instrument metric.Float64Observable, value float64, _ ...metric.ObserveOption) {
+	o.float64s[instrument] = value
+}
+
+// TestCheckPods tests that every ready pod of the backend Service is probed, and
+// that ready pods failing the check are reported
+func TestCheckPods(t *testing.T) {
+	ingress := statusServer(t, http.StatusOK)
+	healthy := statusServer(t, http.StatusOK)
+	failing := statusServer(t, http.StatusServiceUnavailable)
+
+	client := newTestCluster(t, discovery.BackendOptions{Pods: true},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.0.0.10",
+				Ports:     []corev1.ServicePort{{Name: "http", Port: 80}},
+			},
+		},
+		endpointSlice("web", "web-healthy", serverPort(healthy), true),
+		endpointSlice("web", "web-failing", serverPort(failing), true),
+		// Not ready pods are reported without being probed
+		endpointSlice("web", "web-starting", 1, false),
+	)
+	provider := newTestProvider(t)
+	m := NewMonitor([]*discovery.Client{client}, provider)
+
+	endpoint := discovery.Endpoint{
+		Cluster:         "test",
+		Source:          discovery.SourceIngress,
+		Namespace:       "default",
+		IngressName:     "web",
+		ServiceName:     "web",
+		ServicePortName: "http",
+		Annotations:     map[string]string{discovery.AnnotationProbePods: "true"},
+		URL:             ingress.URL,
+		Path:            "/healthz",
+	}
+	result := m.checkEndpoint(context.Background(), endpoint)
+
+	if !result.Up {
+		t.Errorf("Expected the endpoint to be up, got %+v", result)
+	}
+	if result.Backend != nil {
+		t.Errorf("Expected the backend Service not to be probed, got %+v", result.Backend)
+	}
+
+	pods := make(map[string]PodResult)
+	for _, pod := range result.Pods {
+		pods[pod.Pod] = pod
+	}
+	if len(pods) != 3 {
+		t.Fatalf("Expected 3 pods, got %+v", result.Pods)
+	}
+	if pod := pods["web-healthy"]; !pod.Up || !pod.Ready || pod.StatusCode != http.StatusOK || pod.URL != healthy.URL+"/healthz" {
+		t.Errorf("Expected web-healthy to be up at %s/healthz, got %+v", healthy.URL, pod)
+	}
+	if pod := pods["web-failing"]; pod.Up || !pod.Ready || pod.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected web-failing to be ready but failing with 503, got %+v", pod)
+	}
+	if pod := pods["web-starting"]; pod.Up || pod.Ready || pod.StatusCode != 0 || pod.Error == "" {
+		t.Errorf("Expected web-starting to be reported as not ready without a probe, got %+v", pod)
+	}
+
+	if healthyPods, readyFailing := podCounts(result.Pods); healthyPods != 1 || readyFailing != 1 {
+		t.Errorf("Expected 1 healthy and 1 ready but failing pod, got %d and %d", healthyPods, readyFailing)
+	}
+
+	o := &recordingObserver{
+		int64s:   make(map[metric.Int64Observable]int64),
+		float64s: make(map[metric.Float64Observable]float64),
+	}
+	if err := m.observe(context.Background(), o); err != nil {
+		t.Fatalf("observe() error = %v", err)
+	}
+	if ratio, ok := o.float64s[provider.GetPodsHealthyRatioGauge()]; !ok || ratio != 1.0/3 {
+		t.Errorf("Expected a healthy pods ratio of 1/3, got %v (observed: %v)", ratio, ok)
+	}
+	if readyFailing, ok := o.int64s[provider.GetPodsReadyFailingGauge()]; !ok || readyFailing != 1 {
+		t.Errorf("Expected 1 ready but failing pod, got %v (observed: %v)", readyFailing, ok)
+	}
+	if up := o.int64s[provider.GetUpGauge()]; up != 1 {
+		t.Errorf("Expected the endpoint to be observed up, got %d", up)
+	}
+}
@@ -0,0 +1,86 @@
+package monitoring
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of event published on the bus
+type EventType string
+
+const (
+	// EventCheck is published after every completed endpoint check
+	EventCheck EventType = "check"
+	// EventTransition is published when an endpoint goes from up to down or back
+	EventTransition EventType = "transition"
+	// EventState describes the current state of an endpoint, used to replay state to new subscribers
+	EventState EventType = "state"
+)
+
+// Event is a check result or state change published on the bus
+type Event struct {
+	Type       EventType         `json:"type"`
+	Time       time.Time         `json:"time"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Result     CheckResult       `json:"result"`
+	PreviousUp *bool             `json:"previousUp,omitempty"`
+}
+
+// Bus is an in-process publish/subscribe bus for monitoring events
+type Bus struct {
+	mu          sync.RWMutex // Protects subscribers and nextID
+	subscribers map[int]chan Event
+	nextID      int
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[int]chan Event),
+	}
+}
+
+// Subscribe registers a new subscriber with the given channel buffer size. The
+// returned function must be called to unsubscribe and release the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Event, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Subscribers returns the number of current subscribers
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Publish delivers an event to all subscribers. Subscribers whose buffer is full
+// miss the event rather than blocking the monitor.
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
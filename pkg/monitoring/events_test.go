@@ -0,0 +1,103 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+)
+
+// TestBusPublishSubscribe tests delivery of events to subscribers
+func TestBusPublishSubscribe(t *testing.T) {
+	bus := NewBus()
+
+	first, unsubscribeFirst := bus.Subscribe(1)
+	second, unsubscribeSecond := bus.Subscribe(1)
+	defer unsubscribeSecond()
+
+	bus.Publish(Event{Type: EventCheck})
+
+	for i, ch := range []<-chan Event{first, second} {
+		select {
+		case event := <-ch:
+			if event.Type != EventCheck {
+				t.Errorf("Subscriber %d received event type %s, expected %s", i, event.Type, EventCheck)
+			}
+		default:
+			t.Errorf("Subscriber %d did not receive the event", i)
+		}
+	}
+
+	if bus.Subscribers() != 2 {
+		t.Errorf("Expected 2 subscribers, got %d", bus.Subscribers())
+	}
+
+	// Unsubscribed channels are closed and no longer receive events
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if bus.Subscribers() != 1 {
+		t.Errorf("Expected 1 subscriber after unsubscribing, got %d", bus.Subscribers())
+	}
+	bus.Publish(Event{Type: EventCheck})
+	if _, ok := <-first; ok {
+		t.Errorf("Expected unsubscribed channel to be closed")
+	}
+}
+
+// TestBusSlowSubscriber tests that a full subscriber does not block publishing
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	ch, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		bus.Publish(Event{Type: EventCheck})
+		bus.Publish(Event{Type: EventTransition})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+
+	if event := <-ch; event.Type != EventCheck {
+		t.Errorf("Expected the first event to be kept, got %s", event.Type)
+	}
+}
+
+// TestRecordResultTransitions tests that state transitions are published
+func TestRecordResultTransitions(t *testing.T) {
+	m := NewMonitor(nil, nil)
+	events, unsubscribe := m.Events().Subscribe(10)
+	defer unsubscribe()
+
+	endpoint := discovery.Endpoint{Namespace: "default", IngressName: "ingress", URL: "http://example.com", Path: "/"}
+	key := endpointKey(endpoint)
+
+	m.recordResult(key, endpoint, CheckResult{Up: true})
+	m.recordResult(key, endpoint, CheckResult{Up: true})
+	m.recordResult(key, endpoint, CheckResult{Up: false})
+
+	var types []EventType
+	for len(events) > 0 {
+		event := <-events
+		types = append(types, event.Type)
+		if event.Type == EventTransition && (event.PreviousUp == nil || !*event.PreviousUp) {
+			t.Errorf("Expected transition to record the previous up state")
+		}
+	}
+
+	expected := []EventType{EventCheck, EventCheck, EventCheck, EventTransition}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("Expected events %v, got %v", expected, types)
+			break
+		}
+	}
+}
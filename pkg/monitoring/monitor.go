@@ -2,33 +2,118 @@ package monitoring
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
 	"github.com/exo7-ca/k8s-http-monitor/pkg/discovery"
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
 	"github.com/exo7-ca/k8s-http-monitor/pkg/metrics"
 )
 
 // Monitor checks the health of endpoints
 type Monitor struct {
-	discoveryClient    *discovery.Client
+	discoveryClients   []*discovery.Client
 	metricsProvider    *metrics.Provider
+	settingsMu         sync.RWMutex // Protects checkInterval and successStatusCodes, which can be reloaded
 	checkInterval      time.Duration
+	successStatusCodes []int
 	timeout            time.Duration
 	httpClient         *http.Client
-	mu                 sync.Mutex // Protects the maps below
+	backendClient      *http.Client            // Probes backend Services directly, without verifying certificates
+	probeClientsMu     sync.Mutex              // Protects probeClients
+	probeClients       map[string]*http.Client // Clients dialing a probe address override, by address
+	mu                 sync.Mutex              // Protects the maps below
 	endpointStatus     map[string]bool
 	endpoints          map[string]discovery.Endpoint
-	successStatusCodes []int
+	results            map[string]CheckResult
+	events             *Bus
+	lastProgress       atomic.Int64 // Unix nanoseconds of the last scheduler iteration
+	inflight           sync.WaitGroup
+	done               chan struct{} // Closed when the check loop exits
+	active             atomic.Bool
+	trigger            chan struct{} // Requests an immediate check cycle
+	reschedule         chan struct{} // Signals a change of the check interval
+	ownsEndpoint       func(key string) bool
+	location           *Location
+	remoteHostPatterns []string
+	verifyRedirect     bool
+}
+
+// Location identifies where the monitor probes from
+type Location struct {
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// CheckResult holds the outcome of a single endpoint check
+type CheckResult struct {
+	ID             string          `json:"id"`
+	Cluster        string          `json:"cluster,omitempty"`
+	Namespace      string          `json:"namespace"`
+	Ingress        string          `json:"ingress"`
+	Service        string          `json:"service"`
+	URL            string          `json:"url"`
+	Up             bool            `json:"up"`
+	StatusCode     int             `json:"statusCode,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ResponseTimeMs float64         `json:"responseTimeMs"`
+	CheckedAt      time.Time       `json:"checkedAt"`
+	Location       *Location       `json:"location,omitempty"`
+	HTTPSRedirect  *RedirectResult `json:"httpsRedirect,omitempty"`
+	Backend        *BackendResult  `json:"backend,omitempty"`
+	// IngressOnlyFailure is set when the endpoint is down while its backend
+	// Service is up, pointing at the ingress controller rather than the application
+	IngressOnlyFailure bool `json:"ingressOnlyFailure,omitempty"`
+	// Pods holds the results of probing every pod of the backend Service, for
+	// Ingresses that opt into pod probing
+	Pods []PodResult `json:"pods,omitempty"`
+}
+
+// RedirectResult holds the outcome of checking that the http URL of an https
+// endpoint redirects to https
+type RedirectResult struct {
+	OK         bool   `json:"ok"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
+// EndpointStatus describes a monitored endpoint and its most recent check result
+type EndpointStatus struct {
+	ID           string            `json:"id"`
+	Cluster      string            `json:"cluster,omitempty"`
+	Source       string            `json:"source,omitempty"`
+	Namespace    string            `json:"namespace"`
+	Ingress      string            `json:"ingress"`
+	IngressClass string            `json:"ingressClass,omitempty"`
+	Service      string            `json:"service"`
+	URL          string            `json:"url"`
+	ProbeAddress string            `json:"probeAddress,omitempty"`
+	ProbeMode    string            `json:"probeMode,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	LastResult   *CheckResult      `json:"lastResult,omitempty"`
+}
+
+// ErrEndpointNotFound is returned when an on-demand check targets an unknown endpoint
+var ErrEndpointNotFound = errors.New("endpoint not found")
+
 // Option is a functional option for configuring the monitor
 type Option func(*Monitor)
 
@@ -53,25 +138,105 @@ func WithSuccessStatusCodes(codes []int) Option {
 	}
 }
 
+// WithShardFilter restricts checks to the endpoints whose key is owned by this replica
+func WithShardFilter(owns func(key string) bool) Option {
+	return func(m *Monitor) {
+		m.ownsEndpoint = owns
+	}
+}
+
+// WithLocation sets the probe location reported with every check result
+func WithLocation(location Location) Option {
+	return func(m *Monitor) {
+		if location != (Location{}) {
+			m.location = &location
+		}
+	}
+}
+
+// WithRemoteHostPatterns restricts checks to endpoints whose host matches one of
+// the glob patterns, for monitors probing public hosts from outside their home cluster
+func WithRemoteHostPatterns(patterns []string) Option {
+	return func(m *Monitor) {
+		m.remoteHostPatterns = patterns
+	}
+}
+
+// WithHTTPSRedirectCheck enables checking that the http URL of every https endpoint
+// redirects to https. The health.monitor/verify-https-redirect annotation overrides
+// it per Ingress.
+func WithHTTPSRedirectCheck(enabled bool) Option {
+	return func(m *Monitor) {
+		m.verifyRedirect = enabled
+	}
+}
+
 func endpointKey(endpoint discovery.Endpoint) string {
-	return fmt.Sprintf("%s/%s/%s%s",
+	key := fmt.Sprintf("%s/%s/%s%s",
 		endpoint.Namespace,
 		endpoint.IngressName,
 		endpoint.URL,
 		endpoint.Path)
+
+	// Prefix the cluster so the same Ingress in several clusters is tracked separately
+	if endpoint.Cluster != "" {
+		key = endpoint.Cluster + "/" + key
+	}
+	return key
 }
 
-// NewMonitor creates a new endpoint monitor
-func NewMonitor(discoveryClient *discovery.Client, metricsProvider *metrics.Provider, options ...Option) *Monitor {
+// endpointAttributes returns the metric attributes describing an endpoint
+func endpointAttributes(endpoint discovery.Endpoint) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("cluster", endpoint.Cluster),
+		attribute.String("source", string(endpoint.Source)),
+		attribute.String("namespace", endpoint.Namespace),
+		attribute.String("service", endpoint.ServiceName),
+		attribute.String("ingress", endpoint.IngressName),
+		attribute.String("ingress_class", endpoint.IngressClass),
+		attribute.String("probe_mode", string(endpoint.ProbeMode)),
+		attribute.String("url", endpoint.URL+endpoint.Path),
+	}
+
+	// Add labels as attributes
+	for k, v := range endpoint.Labels {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return attrs
+}
+
+// boolValue returns 1 for true and 0 for false, for gauges
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// endpointID returns a short, URL-safe identifier derived from the endpoint key
+func endpointID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewMonitor creates a new endpoint monitor that checks the endpoints discovered by each client
+func NewMonitor(discoveryClients []*discovery.Client, metricsProvider *metrics.Provider, options ...Option) *Monitor {
 	m := &Monitor{
-		discoveryClient:    discoveryClient,
+		discoveryClients:   discoveryClients,
 		metricsProvider:    metricsProvider,
 		checkInterval:      30 * time.Second,
 		timeout:            10 * time.Second,
 		endpointStatus:     make(map[string]bool),
 		endpoints:          make(map[string]discovery.Endpoint),
+		results:            make(map[string]CheckResult),
 		successStatusCodes: []int{401, 403, 404}, // Default success status codes
+		events:             NewBus(),
+		trigger:            make(chan struct{}, 1),
+		reschedule:         make(chan struct{}, 1),
+		probeClients:       make(map[string]*http.Client),
 	}
+	m.active.Store(true)
 
 	// Apply options
 	for _, option := range options {
@@ -83,6 +248,14 @@ func NewMonitor(discoveryClient *discovery.Client, metricsProvider *metrics.Prov
 		Timeout: m.timeout,
 	}
 
+	// Backends are probed by address, which their certificates do not cover
+	backendTransport := http.DefaultTransport.(*http.Transport).Clone()
+	backendTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	m.backendClient = &http.Client{
+		Timeout:   m.timeout,
+		Transport: backendTransport,
+	}
+
 	return m
 }
 
@@ -90,53 +263,28 @@ func NewMonitor(discoveryClient *discovery.Client, metricsProvider *metrics.Prov
 func (m *Monitor) Start(ctx context.Context) {
 	// Register callback for the upGauge observable metric
 	_, err := m.metricsProvider.GetMeter().RegisterCallback(
-		func(ctx context.Context, o metric.Observer) error {
-			m.mu.Lock()
-			defer m.mu.Unlock()
-
-			for key, endpoint := range m.endpoints {
-				isUp, exists := m.endpointStatus[key]
-				if !exists {
-					continue
-				}
-
-				// Create attributes for this endpoint
-				attrs := []attribute.KeyValue{
-					attribute.String("namespace", endpoint.Namespace),
-					attribute.String("service", endpoint.ServiceName),
-					attribute.String("ingress", endpoint.IngressName),
-					attribute.String("url", endpoint.URL+endpoint.Path),
-				}
-
-				// Add labels as attributes
-				for k, v := range endpoint.Labels {
-					attrs = append(attrs, attribute.String(k, v))
-				}
-
-				// Set gauge value: 1 if up, 0 if down
-				value := int64(0)
-				if isUp {
-					value = 1
-				}
-
-				o.ObserveInt64(m.metricsProvider.GetUpGauge(), value, metric.WithAttributes(attrs...))
-			}
-
-			return nil
-		},
+		m.observe,
 		m.metricsProvider.GetUpGauge(),
+		m.metricsProvider.GetBackendUpGauge(),
+		m.metricsProvider.GetIngressOnlyFailureGauge(),
+		m.metricsProvider.GetPodsHealthyRatioGauge(),
+		m.metricsProvider.GetPodsReadyFailingGauge(),
 	)
 
 	if err != nil {
-		log.Printf("Error registering callback for upGauge: %v", err)
+		logging.Errorf("Error registering callback for upGauge: %v", err)
 	}
 
 	// Start periodic health checks
+	m.done = make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(m.checkInterval)
+		defer close(m.done)
+
+		ticker := time.NewTicker(m.CheckInterval())
 		defer ticker.Stop()
 
 		// Do an initial check
+		m.lastProgress.Store(time.Now().UnixNano())
 		m.checkEndpoints(ctx)
 
 		for {
@@ -144,28 +292,202 @@ func (m *Monitor) Start(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
+				m.lastProgress.Store(time.Now().UnixNano())
+				m.checkEndpoints(ctx)
+			case <-m.trigger:
+				m.lastProgress.Store(time.Now().UnixNano())
 				m.checkEndpoints(ctx)
+			case <-m.reschedule:
+				ticker.Reset(m.CheckInterval())
+			}
+		}
+	}()
+}
+
+// observe reports the state of every checked endpoint to the observable gauges
+func (m *Monitor) observe(ctx context.Context, o metric.Observer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, endpoint := range m.endpoints {
+		isUp, exists := m.endpointStatus[key]
+		if !exists {
+			continue
+		}
+
+		// Create attributes for this endpoint
+		attrs := endpointAttributes(endpoint)
+
+		// Set gauge value: 1 if up, 0 if down
+		value := int64(0)
+		if isUp {
+			value = 1
+		}
+
+		o.ObserveInt64(m.metricsProvider.GetUpGauge(), value, metric.WithAttributes(attrs...))
+
+		// Backend state, when the backend Service was probed
+		if result := m.results[key]; result.Backend != nil {
+			o.ObserveInt64(m.metricsProvider.GetBackendUpGauge(), boolValue(result.Backend.Up), metric.WithAttributes(attrs...))
+			o.ObserveInt64(m.metricsProvider.GetIngressOnlyFailureGauge(), boolValue(result.IngressOnlyFailure), metric.WithAttributes(attrs...))
+		}
+
+		// Pod health, when the pods of the backend Service were probed
+		if result := m.results[key]; len(result.Pods) > 0 {
+			healthy, readyFailing := podCounts(result.Pods)
+			o.ObserveFloat64(m.metricsProvider.GetPodsHealthyRatioGauge(), float64(healthy)/float64(len(result.Pods)), metric.WithAttributes(attrs...))
+			o.ObserveInt64(m.metricsProvider.GetPodsReadyFailingGauge(), int64(readyFailing), metric.WithAttributes(attrs...))
+		}
+	}
+
+	return nil
+}
+
+// SetActive enables or disables scheduled checks. An inactive monitor keeps its
+// scheduler running but performs no checks and reports no endpoint state, which
+// lets standby replicas take over quickly without double-checking endpoints.
+func (m *Monitor) SetActive(active bool) {
+	if m.active.Swap(active) == active {
+		return
+	}
+
+	if !active {
+		// Forget endpoint state so that this replica stops reporting it
+		m.mu.Lock()
+		m.endpointStatus = make(map[string]bool)
+		m.endpoints = make(map[string]discovery.Endpoint)
+		m.results = make(map[string]CheckResult)
+		m.mu.Unlock()
+		return
+	}
+
+	// Run a check cycle right away instead of waiting for the next tick
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Rebalance forgets the state of endpoints this replica no longer owns and runs
+// a check cycle right away so that newly assigned endpoints are checked promptly
+func (m *Monitor) Rebalance() {
+	if m.ownsEndpoint != nil {
+		m.mu.Lock()
+		for key := range m.endpoints {
+			if !m.ownsEndpoint(key) {
+				delete(m.endpoints, key)
+				delete(m.endpointStatus, key)
+				delete(m.results, key)
 			}
 		}
+		m.mu.Unlock()
+	}
+
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown waits for the check loop to exit and in-flight checks to complete.
+// The context passed to Start must be cancelled first.
+func (m *Monitor) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		if m.done != nil {
+			<-m.done
+		}
+		m.inflight.Wait()
+		close(drained)
 	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for in-flight checks: %w", ctx.Err())
+	}
 }
 
 // checkEndpoints discovers and checks all endpoints
 func (m *Monitor) checkEndpoints(ctx context.Context) {
-	endpoints, err := m.discoveryClient.DiscoverIngressEndpoints(ctx)
-	if err != nil {
-		log.Printf("Error discovering endpoints: %v", err)
+	if !m.active.Load() {
 		return
 	}
 
+	// A failing cluster does not prevent checking the others
+	var endpoints []discovery.Endpoint
+	discovered := make(map[string]bool, len(m.discoveryClients))
+	for _, client := range m.discoveryClients {
+		clusterEndpoints, err := client.DiscoverEndpoints(ctx)
+		if err != nil {
+			logging.Errorf("Error discovering endpoints in cluster %q: %v", client.Cluster(), err)
+			continue
+		}
+		discovered[client.Cluster()] = true
+		endpoints = append(endpoints, clusterEndpoints...)
+	}
+
+	// In-flight checks are not cancelled on shutdown so that they can be drained
+	checkCtx := context.WithoutCancel(ctx)
+	checked := make(map[string]bool, len(endpoints))
 	for _, endpoint := range endpoints {
-		go m.checkEndpoint(ctx, endpoint)
+		key := endpointKey(endpoint)
+		if m.ownsEndpoint != nil && !m.ownsEndpoint(key) {
+			continue
+		}
+		if len(m.remoteHostPatterns) > 0 && !matchesHost(endpoint, m.remoteHostPatterns) {
+			continue
+		}
+		checked[key] = true
+
+		m.inflight.Add(1)
+		go func(endpoint discovery.Endpoint) {
+			defer m.inflight.Done()
+			m.checkEndpoint(checkCtx, endpoint)
+		}(endpoint)
+	}
+
+	m.forgetVanished(discovered, checked)
+}
+
+// forgetVanished drops the state of endpoints that are no longer discovered in
+// the given clusters, e.g. after an Ingress was deleted or the namespace filter
+// was reloaded, so that they stop being reported
+func (m *Monitor) forgetVanished(clusters map[string]bool, current map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, endpoint := range m.endpoints {
+		if clusters[endpoint.Cluster] && !current[key] {
+			delete(m.endpoints, key)
+			delete(m.endpointStatus, key)
+			delete(m.results, key)
+		}
+	}
+}
+
+// matchesHost reports whether the endpoint's host matches any of the glob patterns
+func matchesHost(endpoint discovery.Endpoint, patterns []string) bool {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
 	}
+	return false
 }
 
 func (m *Monitor) checkStatus(statusCode int) bool {
 	success := statusCode >= 200 && statusCode < 300
 
+	m.settingsMu.RLock()
+	defer m.settingsMu.RUnlock()
 	for _, code := range m.successStatusCodes {
 		if statusCode == code {
 			success = true
@@ -176,50 +498,381 @@ func (m *Monitor) checkStatus(statusCode int) bool {
 	return success
 }
 
-// checkEndpoint checks a single endpoint
-func (m *Monitor) checkEndpoint(ctx context.Context, endpoint discovery.Endpoint) {
+// LastProgress returns the time of the last scheduler iteration, or the zero
+// time if monitoring has not started yet
+func (m *Monitor) LastProgress() time.Time {
+	nanos := m.lastProgress.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// CheckInterval returns the interval between scheduled health checks
+func (m *Monitor) CheckInterval() time.Duration {
+	m.settingsMu.RLock()
+	defer m.settingsMu.RUnlock()
+	return m.checkInterval
+}
+
+// SetCheckInterval changes the interval between scheduled health checks of a
+// running monitor; the next check runs one new interval from now
+func (m *Monitor) SetCheckInterval(interval time.Duration) {
+	m.settingsMu.Lock()
+	changed := m.checkInterval != interval
+	m.checkInterval = interval
+	m.settingsMu.Unlock()
+
+	if changed {
+		select {
+		case m.reschedule <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetSuccessStatusCodes replaces the HTTP status codes that are considered
+// successful; checks already in flight may still use the previous codes
+func (m *Monitor) SetSuccessStatusCodes(codes []int) {
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+	m.successStatusCodes = append([]int(nil), codes...)
+}
+
+// Events returns the bus on which check results and state transitions are published
+func (m *Monitor) Events() *Bus {
+	return m.events
+}
+
+// recordResult stores the result of a check and publishes it on the event bus
+func (m *Monitor) recordResult(key string, endpoint discovery.Endpoint, result CheckResult) {
+	// Results of checks that complete after the monitor went inactive are dropped
+	if !m.active.Load() {
+		return
+	}
+
+	m.mu.Lock()
+	previousUp, known := m.endpointStatus[key]
+	m.endpointStatus[key] = result.Up
+	m.results[key] = result
+	m.mu.Unlock()
+
+	m.events.Publish(Event{
+		Type:   EventCheck,
+		Time:   result.CheckedAt,
+		Labels: endpoint.Labels,
+		Result: result,
+	})
+
+	if known && previousUp != result.Up {
+		m.events.Publish(Event{
+			Type:       EventTransition,
+			Time:       result.CheckedAt,
+			Labels:     endpoint.Labels,
+			Result:     result,
+			PreviousUp: &previousUp,
+		})
+	}
+}
+
+// Endpoints returns a snapshot of all known endpoints with their latest results
+func (m *Monitor) Endpoints() []EndpointStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(m.endpoints))
+	for key, endpoint := range m.endpoints {
+		status := EndpointStatus{
+			ID:           endpointID(key),
+			Cluster:      endpoint.Cluster,
+			Source:       string(endpoint.Source),
+			Namespace:    endpoint.Namespace,
+			Ingress:      endpoint.IngressName,
+			IngressClass: endpoint.IngressClass,
+			Service:      endpoint.ServiceName,
+			URL:          endpoint.URL + endpoint.Path,
+			ProbeAddress: endpoint.ProbeAddress,
+			ProbeMode:    string(endpoint.ProbeMode),
+			Labels:       endpoint.Labels,
+		}
+		if result, ok := m.results[key]; ok {
+			status.LastResult = &result
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
+
+// SkippedRules returns the Ingress rules that are not monitored in every cluster,
+// as of the last discovery
+func (m *Monitor) SkippedRules() []discovery.SkippedRule {
+	skipped := []discovery.SkippedRule{}
+	for _, client := range m.discoveryClients {
+		skipped = append(skipped, client.SkippedRules()...)
+	}
+	return skipped
+}
+
+// CheckEndpointByID runs a synchronous check of the endpoint with the given ID.
+// A check cancelled through the context is not recorded and returns its error.
+func (m *Monitor) CheckEndpointByID(ctx context.Context, id string) (CheckResult, error) {
+	m.mu.Lock()
+	var target *discovery.Endpoint
+	for key, endpoint := range m.endpoints {
+		if endpointID(key) == id {
+			target = &endpoint
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if target == nil {
+		return CheckResult{}, ErrEndpointNotFound
+	}
+
+	result := m.checkEndpoint(ctx, *target)
+	if err := ctx.Err(); err != nil {
+		return CheckResult{}, err
+	}
+	return result, nil
+}
+
+// CheckIngress runs synchronous checks of every endpoint belonging to an ingress.
+// An empty cluster matches the ingress in every cluster. Checks cancelled through
+// the context are not recorded.
+func (m *Monitor) CheckIngress(ctx context.Context, cluster, namespace, ingress string) ([]CheckResult, error) {
+	m.mu.Lock()
+	var targets []discovery.Endpoint
+	for _, endpoint := range m.endpoints {
+		if (cluster == "" || endpoint.Cluster == cluster) && endpoint.Namespace == namespace && endpoint.IngressName == ingress {
+			targets = append(targets, endpoint)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(targets) == 0 {
+		return nil, ErrEndpointNotFound
+	}
+
+	results := make([]CheckResult, len(targets))
+	var wg sync.WaitGroup
+	for i, endpoint := range targets {
+		wg.Add(1)
+		go func(i int, endpoint discovery.Endpoint) {
+			defer wg.Done()
+			results[i] = m.checkEndpoint(ctx, endpoint)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// clientFor returns the HTTP client used to probe the endpoint. Endpoints with a
+// probe address are dialed at that host on the port of the request URL, so that
+// http requests, such as the redirect check of an https endpoint, and https
+// requests each reach the port of their scheme. The URL host is still used for
+// the Host header and TLS server name.
+func (m *Monitor) clientFor(endpoint discovery.Endpoint) *http.Client {
+	if endpoint.ProbeAddress == "" {
+		return m.httpClient
+	}
+
+	m.probeClientsMu.Lock()
+	defer m.probeClientsMu.Unlock()
+
+	if client, ok := m.probeClients[endpoint.ProbeAddress]; ok {
+		return client
+	}
+
+	probeHost := endpoint.ProbeAddress
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: m.timeout}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(probeHost, port))
+	}
+
+	client := &http.Client{
+		Timeout:   m.timeout,
+		Transport: transport,
+	}
+	m.probeClients[endpoint.ProbeAddress] = client
+	return client
+}
+
+// verifiesRedirect reports whether the http to https redirect of the endpoint is checked
+func (m *Monitor) verifiesRedirect(endpoint discovery.Endpoint) bool {
+	if !strings.HasPrefix(endpoint.URL, "https://") {
+		return false
+	}
+	if enabled, err := strconv.ParseBool(endpoint.Annotations[discovery.AnnotationVerifyHTTPSRedirect]); err == nil {
+		return enabled
+	}
+	return m.verifyRedirect
+}
+
+// checkHTTPSRedirect requests the http URL of an https endpoint without following
+// redirects and checks that it redirects to https
+func (m *Monitor) checkHTTPSRedirect(ctx context.Context, endpoint discovery.Endpoint) *RedirectResult {
+	httpURL := "http://" + strings.TrimPrefix(endpoint.URL, "https://") + endpoint.Path
+	result := &RedirectResult{}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", httpURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	client := *m.clientFor(endpoint)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Location = resp.Header.Get("Location")
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		result.Error = fmt.Sprintf("expected a redirect, got status %d", resp.StatusCode)
+		return result
+	}
+
+	location, err := req.URL.Parse(result.Location)
+	if err != nil || location.Scheme != "https" {
+		result.Error = fmt.Sprintf("redirect location %q is not https", result.Location)
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// checkEndpoint checks a single endpoint and returns the result
+func (m *Monitor) checkEndpoint(ctx context.Context, endpoint discovery.Endpoint) CheckResult {
 	fullURL := endpoint.URL + endpoint.Path
-	log.Printf("Checking endpoint: %s", fullURL)
+	logging.Debugf("Checking endpoint: %s", fullURL)
 
 	// Store endpoint information
 	key := endpointKey(endpoint)
-	m.mu.Lock()
-	m.endpoints[key] = endpoint
-	m.mu.Unlock()
+	if m.active.Load() {
+		m.mu.Lock()
+		m.endpoints[key] = endpoint
+		m.mu.Unlock()
+	}
+
+	result := CheckResult{
+		ID:        endpointID(key),
+		Cluster:   endpoint.Cluster,
+		Namespace: endpoint.Namespace,
+		Ingress:   endpoint.IngressName,
+		Service:   endpoint.ServiceName,
+		URL:       fullURL,
+		Location:  m.location,
+	}
 
 	startTime := time.Now()
+	result.CheckedAt = startTime
 
 	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
-		log.Printf("Error creating request for %s: %v", fullURL, err)
-		return
+		logging.Errorf("Error creating request for %s: %v", fullURL, err)
+		result.Error = err.Error()
+		return result
 	}
 
-	resp, err := m.httpClient.Do(req)
+	// The backend Service and its pods are probed concurrently so that they see
+	// the same conditions
+	var backend chan *BackendResult
+	var pods chan []PodResult
+	if client := m.clusterClient(endpoint.Cluster); client != nil {
+		if client.ProbesBackend(endpoint) {
+			backend = make(chan *BackendResult, 1)
+			go func() {
+				backend <- m.checkBackend(ctx, client, endpoint)
+			}()
+		}
+		if client.ProbesPods(endpoint) {
+			pods = make(chan []PodResult, 1)
+			go func() {
+				results, err := m.checkPods(ctx, client, endpoint)
+				if err != nil {
+					logging.Errorf("Error probing the pods of %s: %v", fullURL, err)
+				}
+				pods <- results
+			}()
+		}
+	}
+
+	resp, err := m.clientFor(endpoint).Do(req)
+	if err != nil && ctx.Err() != nil {
+		// The check was abandoned by its caller, e.g. an API client that went away,
+		// which says nothing about the endpoint
+		logging.Debugf("Check of %s was cancelled: %v", fullURL, err)
+		result.Error = err.Error()
+		return result
+	}
 	endTime := time.Now()
 	duration := float64(endTime.Sub(startTime).Milliseconds())
+	result.ResponseTimeMs = duration
 
 	// Create common attributes
-	attrs := []attribute.KeyValue{
-		attribute.String("namespace", endpoint.Namespace),
-		attribute.String("service", endpoint.ServiceName),
-		attribute.String("ingress", endpoint.IngressName),
-		attribute.String("url", fullURL),
+	attrs := endpointAttributes(endpoint)
+
+	if backend != nil {
+		result.Backend = <-backend
+		up := err == nil && m.checkStatus(resp.StatusCode)
+		result.IngressOnlyFailure = !up && result.Backend.Up
+		if result.IngressOnlyFailure {
+			logging.Warnf("Endpoint %s is DOWN while its backend %s is UP", fullURL, result.Backend.URL)
+		}
+
+		backendStatus := ""
+		if result.Backend.StatusCode != 0 {
+			backendStatus = strconv.Itoa(result.Backend.StatusCode)
+		}
+		backendAttrs := append(attrs,
+			attribute.String("status", backendStatus),
+			attribute.String("success", strconv.FormatBool(result.Backend.Up)),
+		)
+		m.metricsProvider.GetBackendResponseTimeHistogram().Record(ctx, result.Backend.ResponseTimeMs, metric.WithAttributes(backendAttrs...))
+	}
+	if pods != nil {
+		result.Pods = <-pods
 	}
 
-	// Add labels as attributes
-	for k, v := range endpoint.Labels {
-		attrs = append(attrs, attribute.String(k, v))
+	if m.verifiesRedirect(endpoint) {
+		result.HTTPSRedirect = m.checkHTTPSRedirect(ctx, endpoint)
+		if !result.HTTPSRedirect.OK {
+			logging.Warnf("Endpoint %s does not redirect to https: %s", fullURL, result.HTTPSRedirect.Error)
+		}
+		m.metricsProvider.GetRedirectCounter().Add(ctx, 1, metric.WithAttributes(
+			append(attrs, attribute.String("success", strconv.FormatBool(result.HTTPSRedirect.OK)))...))
 	}
 
 	if err != nil {
 		// Handle errors
-		log.Printf("Error checking %s: %v", fullURL, err)
+		logging.Errorf("Error checking %s: %v", fullURL, err)
+		result.Error = err.Error()
 
 		// Update status
-		m.mu.Lock()
-		m.endpointStatus[key] = false
-		m.mu.Unlock()
+		m.recordResult(key, endpoint, result)
 
 		// Record metrics
 		statusAttrs := append(attrs,
@@ -235,18 +888,18 @@ func (m *Monitor) checkEndpoint(ctx context.Context, endpoint discovery.Endpoint
 		defer resp.Body.Close()
 
 		isUp := m.checkStatus(resp.StatusCode)
+		result.Up = isUp
+		result.StatusCode = resp.StatusCode
 
 		// log
 		if isUp {
-			log.Printf("Endpoint %s is UP, status: %d, response time: %.2fms", fullURL, resp.StatusCode, duration)
+			logging.Debugf("Endpoint %s is UP, status: %d, response time: %.2fms", fullURL, resp.StatusCode, duration)
 		} else {
-			log.Printf("Endpoint %s is DOWN, status: %d, response time: %.2fms", fullURL, resp.StatusCode, duration)
+			logging.Warnf("Endpoint %s is DOWN, status: %d, response time: %.2fms", fullURL, resp.StatusCode, duration)
 		}
 
 		// Update status
-		m.mu.Lock()
-		m.endpointStatus[key] = isUp
-		m.mu.Unlock()
+		m.recordResult(key, endpoint, result)
 
 		// Record metrics
 		statusAttrs := append(attrs,
@@ -257,4 +910,6 @@ func (m *Monitor) checkEndpoint(ctx context.Context, endpoint discovery.Endpoint
 		m.metricsProvider.GetRequestCounter().Add(ctx, 1, metric.WithAttributes(statusAttrs...))
 		m.metricsProvider.GetResponseTimeHistogram().Record(ctx, duration, metric.WithAttributes(statusAttrs...))
 	}
+
+	return result
 }
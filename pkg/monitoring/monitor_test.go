@@ -1,6 +1,11 @@
 package monitoring
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -117,6 +122,14 @@ func TestEndpointKey(t *testing.T) {
 	if key != expected {
 		t.Errorf("endpointKey() = %v, expected %v", key, expected)
 	}
+
+	// The cluster is prefixed when set
+	endpoint.Cluster = "prod-eu"
+	key = endpointKey(endpoint)
+	expected = "prod-eu/default/ingress/http://example.com/health"
+	if key != expected {
+		t.Errorf("endpointKey() = %v, expected %v", key, expected)
+	}
 }
 
 // TestCheckEndpointHTTP tests the checkEndpoint function with HTTP responses
@@ -124,3 +137,291 @@ func TestCheckEndpointHTTP(t *testing.T) {
 	// Skip this test as it requires a real metrics provider
 	t.Skip("Skipping test that requires a real metrics provider")
 }
+
+// TestEndpointID tests the endpointID function
+func TestEndpointID(t *testing.T) {
+	id := endpointID("default/ingress/http://example.com/health")
+
+	if len(id) != 16 {
+		t.Errorf("endpointID() returned %q, expected 16 hex characters", id)
+	}
+	if id != endpointID("default/ingress/http://example.com/health") {
+		t.Errorf("endpointID() is not stable for the same key")
+	}
+	if id == endpointID("default/ingress/http://example.com/other") {
+		t.Errorf("endpointID() returned the same ID for different keys")
+	}
+}
+
+// TestCheckEndpointByIDNotFound tests on-demand checks of unknown endpoints
+func TestCheckEndpointByIDNotFound(t *testing.T) {
+	m := NewMonitor(nil, nil)
+
+	if _, err := m.CheckEndpointByID(context.Background(), "unknown"); !errors.Is(err, ErrEndpointNotFound) {
+		t.Errorf("CheckEndpointByID() error = %v, expected %v", err, ErrEndpointNotFound)
+	}
+	if _, err := m.CheckIngress(context.Background(), "", "default", "unknown"); !errors.Is(err, ErrEndpointNotFound) {
+		t.Errorf("CheckIngress() error = %v, expected %v", err, ErrEndpointNotFound)
+	}
+}
+
+// TestShutdownWithoutStart tests that Shutdown returns when monitoring never started
+func TestShutdownWithoutStart(t *testing.T) {
+	m := NewMonitor(nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := m.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v, expected nil", err)
+	}
+}
+
+// TestSetActive tests that an inactive monitor forgets and ignores endpoint state
+func TestSetActive(t *testing.T) {
+	m := NewMonitor(nil, nil)
+
+	endpoint := discovery.Endpoint{Namespace: "default", IngressName: "ingress", URL: "http://example.com", Path: "/"}
+	key := endpointKey(endpoint)
+	m.endpoints[key] = endpoint
+	m.recordResult(key, endpoint, CheckResult{Up: true})
+
+	m.SetActive(false)
+	if len(m.Endpoints()) != 0 {
+		t.Errorf("Expected inactive monitor to forget endpoints, got %d", len(m.Endpoints()))
+	}
+
+	// Results arriving while inactive are dropped
+	m.recordResult(key, endpoint, CheckResult{Up: true})
+	if len(m.endpointStatus) != 0 {
+		t.Errorf("Expected inactive monitor to drop results, got %d", len(m.endpointStatus))
+	}
+
+	// Reactivating requests an immediate check cycle
+	m.SetActive(true)
+	select {
+	case <-m.trigger:
+	default:
+		t.Errorf("Expected reactivation to trigger a check cycle")
+	}
+}
+
+// TestRebalance tests that endpoints no longer owned by this shard are forgotten
+func TestRebalance(t *testing.T) {
+	owned := map[string]bool{}
+	m := NewMonitor(nil, nil, WithShardFilter(func(key string) bool {
+		return owned[key]
+	}))
+
+	first := discovery.Endpoint{Namespace: "default", IngressName: "first", URL: "http://first.example.com", Path: "/"}
+	second := discovery.Endpoint{Namespace: "default", IngressName: "second", URL: "http://second.example.com", Path: "/"}
+	for _, endpoint := range []discovery.Endpoint{first, second} {
+		key := endpointKey(endpoint)
+		owned[key] = true
+		m.endpoints[key] = endpoint
+		m.recordResult(key, endpoint, CheckResult{Up: true})
+	}
+
+	// Hand the second endpoint over to another shard
+	owned[endpointKey(second)] = false
+	m.Rebalance()
+
+	endpoints := m.Endpoints()
+	if len(endpoints) != 1 || endpoints[0].Ingress != "first" {
+		t.Errorf("Expected only the first endpoint to remain, got %+v", endpoints)
+	}
+	if _, ok := m.endpointStatus[endpointKey(second)]; ok {
+		t.Errorf("Expected status of the rebalanced endpoint to be forgotten")
+	}
+
+	select {
+	case <-m.trigger:
+	default:
+		t.Errorf("Expected rebalancing to trigger a check cycle")
+	}
+}
+
+// TestReloadSettings tests changing the interval and success codes of a monitor
+func TestReloadSettings(t *testing.T) {
+	m := NewMonitor(nil, nil, WithCheckInterval(30*time.Second))
+
+	m.SetCheckInterval(time.Minute)
+	if m.CheckInterval() != time.Minute {
+		t.Errorf("Expected check interval %v, got %v", time.Minute, m.CheckInterval())
+	}
+	select {
+	case <-m.reschedule:
+	default:
+		t.Errorf("Expected a changed interval to reschedule the checks")
+	}
+
+	m.SetSuccessStatusCodes([]int{503})
+	if !m.checkStatus(503) || m.checkStatus(404) {
+		t.Errorf("Expected only 503 to be an additional success code, got %v", m.successStatusCodes)
+	}
+}
+
+// TestForgetVanished tests that endpoints no longer discovered stop being reported
+func TestForgetVanished(t *testing.T) {
+	m := NewMonitor(nil, nil)
+
+	kept := discovery.Endpoint{Cluster: "eu", Namespace: "default", IngressName: "kept", URL: "http://kept.example.com", Path: "/"}
+	removed := discovery.Endpoint{Cluster: "eu", Namespace: "filtered", IngressName: "removed", URL: "http://removed.example.com", Path: "/"}
+	unreachable := discovery.Endpoint{Cluster: "us", Namespace: "default", IngressName: "unreachable", URL: "http://us.example.com", Path: "/"}
+	for _, endpoint := range []discovery.Endpoint{kept, removed, unreachable} {
+		key := endpointKey(endpoint)
+		m.endpoints[key] = endpoint
+		m.recordResult(key, endpoint, CheckResult{Up: true})
+	}
+
+	// Only the eu cluster was discovered, without the removed endpoint
+	m.forgetVanished(map[string]bool{"eu": true}, map[string]bool{endpointKey(kept): true})
+
+	var ingresses []string
+	for _, endpoint := range m.Endpoints() {
+		ingresses = append(ingresses, endpoint.Ingress)
+	}
+	if len(ingresses) != 2 {
+		t.Errorf("Expected the kept endpoint and the one of the undiscovered cluster to remain, got %v", ingresses)
+	}
+	if _, ok := m.endpointStatus[endpointKey(removed)]; ok {
+		t.Errorf("Expected status of the removed endpoint to be forgotten")
+	}
+}
+
+// TestMatchesHost tests the remote host pattern matching
+func TestMatchesHost(t *testing.T) {
+	patterns := []string{"*.example.com", "status.example.org"}
+
+	testCases := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://shop.example.com", true},
+		{"https://shop.example.com:8443", true},
+		{"https://status.example.org", true},
+		{"https://internal.example.org", false},
+		{"http://service.namespace.svc.cluster.local", false},
+	}
+
+	for _, tc := range testCases {
+		result := matchesHost(discovery.Endpoint{URL: tc.url}, patterns)
+		if result != tc.expected {
+			t.Errorf("matchesHost(%s) = %v, expected %v", tc.url, result, tc.expected)
+		}
+	}
+}
+
+// TestWithLocation tests the WithLocation option
+func TestWithLocation(t *testing.T) {
+	m := &Monitor{}
+	WithLocation(Location{})(m)
+	if m.location != nil {
+		t.Errorf("Expected an empty location to be ignored, got %+v", m.location)
+	}
+
+	location := Location{Region: "eu-west-1", Zone: "eu-west-1a", Cluster: "prod-eu"}
+	WithLocation(location)(m)
+	if m.location == nil || *m.location != location {
+		t.Errorf("WithLocation(%+v) did not set location correctly, got %+v", location, m.location)
+	}
+}
+
+// TestClientForProbeAddress tests that endpoints with a probe address are dialed at
+// that host, on the port of the URL
+func TestClientForProbeAddress(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	m := NewMonitor(nil, nil)
+	endpoint := discovery.Endpoint{
+		URL:          "http://web.example.com:" + port,
+		ProbeAddress: "127.0.0.1",
+	}
+
+	client := m.clientFor(endpoint)
+	if client == m.httpClient {
+		t.Fatal("Expected a dedicated client for the probe address")
+	}
+	if m.clientFor(endpoint) != client {
+		t.Error("Expected the client to be reused for the same probe address")
+	}
+	if m.clientFor(discovery.Endpoint{URL: "http://web.example.com"}) != m.httpClient {
+		t.Error("Expected the default client without a probe address")
+	}
+
+	resp, err := client.Get(endpoint.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("Expected the probe address to be dialed, got: %v", err)
+	}
+	resp.Body.Close()
+	if host != "web.example.com:"+port {
+		t.Errorf("Expected Host header %q, got %q", "web.example.com:"+port, host)
+	}
+}
+
+// TestCheckHTTPSRedirect tests the verification of http to https redirects
+func TestCheckHTTPSRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "https://"+r.Host+r.URL.Path, http.StatusPermanentRedirect)
+		case "/insecure-redirect":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	m := NewMonitor(nil, nil)
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/redirect", true},
+		{"/insecure-redirect", false},
+		{"/plain", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := m.checkHTTPSRedirect(context.Background(), discovery.Endpoint{
+				URL:          "https://web.example.com:" + port,
+				Path:         tt.path,
+				ProbeAddress: "127.0.0.1",
+			})
+			if result.OK != tt.expected {
+				t.Errorf("Expected OK %v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestVerifiesRedirect tests the global setting and the annotation override
+func TestVerifiesRedirect(t *testing.T) {
+	m := NewMonitor(nil, nil, WithHTTPSRedirectCheck(true))
+	disabled := map[string]string{discovery.AnnotationVerifyHTTPSRedirect: "false"}
+
+	if !m.verifiesRedirect(discovery.Endpoint{URL: "https://web.example.com"}) {
+		t.Error("Expected https endpoints to be verified")
+	}
+	if m.verifiesRedirect(discovery.Endpoint{URL: "http://web.example.com"}) {
+		t.Error("Expected http endpoints not to be verified")
+	}
+	if m.verifiesRedirect(discovery.Endpoint{URL: "https://web.example.com", Annotations: disabled}) {
+		t.Error("Expected the annotation to disable the verification")
+	}
+
+	m = NewMonitor(nil, nil)
+	enabled := map[string]string{discovery.AnnotationVerifyHTTPSRedirect: "true"}
+	if !m.verifiesRedirect(discovery.Endpoint{URL: "https://web.example.com", Annotations: enabled}) {
+		t.Error("Expected the annotation to enable the verification")
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+)
+
+// Server serves the health probes and the API
+type Server struct {
+	httpServer  *http.Server
+	listener    net.Listener
+	tlsCertFile string
+	tlsKeyFile  string
+}
+
+// Option is a functional option for configuring the server
+type Option func(*Server)
+
+// WithReadTimeout sets the maximum duration for reading an entire request
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.httpServer.ReadTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of a response
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.httpServer.WriteTimeout = timeout
+	}
+}
+
+// WithTLS serves HTTPS using the given certificate and key files
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// New creates a new server listening on addr
+func New(addr string, handler http.Handler, options ...Option) *Server {
+	s := &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+
+	// Long-lived requests such as event streams derive their context from the
+	// base context, which is cancelled as soon as shutdown begins
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s.httpServer.BaseContext = func(net.Listener) context.Context {
+		return baseCtx
+	}
+	s.httpServer.RegisterOnShutdown(cancel)
+
+	// Apply options
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Start binds the listen address and serves requests in the background. Errors
+// binding the address are returned immediately; errors while serving are sent
+// on the returned channel.
+func (s *Server) Start() (<-chan error, error) {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+	s.listener = listener
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if s.tlsCertFile != "" {
+			logging.Infof("Starting HTTPS server on %s", listener.Addr())
+			err = s.httpServer.ServeTLS(listener, s.tlsCertFile, s.tlsKeyFile)
+		} else {
+			logging.Infof("Starting HTTP server on %s", listener.Addr())
+			err = s.httpServer.Serve(listener)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	return errCh, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.httpServer.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to complete
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
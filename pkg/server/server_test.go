@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerStartAndShutdown(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	s := New("127.0.0.1:0", mux, WithReadTimeout(time.Second), WithWriteTimeout(time.Second))
+	errCh, err := s.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	resp, err := http.Get("http://" + s.Addr() + "/ping")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Errorf("Expected body %q, got %q", "pong", body)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err, ok := <-errCh; ok {
+		t.Errorf("Expected no serve error after shutdown, got %v", err)
+	}
+}
+
+func TestServerBindError(t *testing.T) {
+	first := New("127.0.0.1:0", http.NewServeMux())
+	if _, err := first.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer first.Shutdown(context.Background())
+
+	second := New(first.Addr(), http.NewServeMux())
+	if _, err := second.Start(); err == nil {
+		second.Shutdown(context.Background())
+		t.Fatalf("Expected an error binding an address already in use")
+	}
+}
+
+func TestShutdownCancelsRequestContext(t *testing.T) {
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	})
+
+	s := New("127.0.0.1:0", mux)
+	if _, err := s.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	go func() {
+		resp, err := http.Get("http://" + s.Addr() + "/stream")
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Expected long-lived request to end on shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package sharding
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/exo7-ca/k8s-http-monitor/pkg/logging"
+)
+
+// Coordinator tracks the replicas behind a headless Service and decides which
+// endpoints this replica owns
+type Coordinator struct {
+	identity        string
+	informerFactory informers.SharedInformerFactory
+	sliceLister     discoverylisters.EndpointSliceLister
+	slicesSynced    cache.InformerSynced
+	mu              sync.RWMutex // Protects ring
+	ring            *Ring
+	onChange        func()
+}
+
+// NewCoordinator creates a coordinator that discovers peers from the EndpointSlices
+// of the given headless Service. onChange is called whenever membership changes.
+func NewCoordinator(client kubernetes.Interface, namespace, service, identity string, onChange func()) *Coordinator {
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labels.Set{discoveryv1.LabelServiceName: service}.String()
+		}),
+	)
+	sliceInformer := informerFactory.Discovery().V1().EndpointSlices()
+
+	c := &Coordinator{
+		identity:        identity,
+		informerFactory: informerFactory,
+		sliceLister:     sliceInformer.Lister(),
+		ring:            NewRing([]string{identity}, DefaultVirtualNodes),
+		onChange:        onChange,
+	}
+
+	// The handler has synced once the ring was rebuilt from every initial slice.
+	// Adding a handler only fails on a stopped informer, which this one is not.
+	registration, _ := sliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.rebuild() },
+		UpdateFunc: func(interface{}, interface{}) { c.rebuild() },
+		DeleteFunc: func(interface{}) { c.rebuild() },
+	})
+	c.slicesSynced = registration.HasSynced
+
+	return c
+}
+
+// Start watches peer EndpointSlices until the context is cancelled
+func (c *Coordinator) Start(ctx context.Context) {
+	c.informerFactory.Start(ctx.Done())
+}
+
+// HasSynced reports whether the peer list has been fully populated and the ring
+// built from it
+func (c *Coordinator) HasSynced() bool {
+	return c.slicesSynced()
+}
+
+// WaitForSync blocks until the peer list has synced or the context is cancelled
+func (c *Coordinator) WaitForSync(ctx context.Context) bool {
+	return cache.WaitForCacheSync(ctx.Done(), c.HasSynced)
+}
+
+// Identity returns this replica's shard identity
+func (c *Coordinator) Identity() string {
+	return c.identity
+}
+
+// Owns reports whether this replica is responsible for the given endpoint key.
+// Nothing is owned until the peers are known, so that replicas starting together
+// do not all check every endpoint.
+func (c *Coordinator) Owns(key string) bool {
+	if !c.HasSynced() {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ring.Owner(key) == c.identity
+}
+
+// Members returns the current shard members
+func (c *Coordinator) Members() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ring.Members()
+}
+
+// rebuild recomputes the ring from the EndpointSlice cache
+func (c *Coordinator) rebuild() {
+	slices, err := c.sliceLister.List(labels.Everything())
+	if err != nil {
+		logging.Errorf("Error listing peer EndpointSlices: %v", err)
+		return
+	}
+
+	members := peerNames(slices)
+	// This replica always takes part, even before its own endpoint is published
+	members = append(members, c.identity)
+	ring := NewRing(members, DefaultVirtualNodes)
+
+	c.mu.Lock()
+	changed := !equalMembers(c.ring.Members(), ring.Members())
+	if changed {
+		c.ring = ring
+	}
+	c.mu.Unlock()
+
+	if changed {
+		logging.Infof("Shard membership changed: %s", strings.Join(ring.Members(), ", "))
+		if c.onChange != nil {
+			c.onChange()
+		}
+	}
+}
+
+// peerNames extracts the pod names of non-terminating endpoints
+func peerNames(slices []*discoveryv1.EndpointSlice) []string {
+	var names []string
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating {
+				continue
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Name != "" {
+				names = append(names, endpoint.TargetRef.Name)
+			} else if endpoint.Hostname != nil && *endpoint.Hostname != "" {
+				names = append(names, *endpoint.Hostname)
+			}
+		}
+	}
+	return names
+}
+
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPeerSlice(name string, pods map[string]bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "monitoring",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "k8s-http-monitor-peers"},
+		},
+	}
+	for pod, terminating := range pods {
+		terminating := terminating
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Terminating: &terminating},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod},
+		})
+	}
+	return slice
+}
+
+func TestPeerNames(t *testing.T) {
+	names := peerNames([]*discoveryv1.EndpointSlice{
+		newPeerSlice("a", map[string]bool{"pod-a": false, "pod-b": true}),
+		newPeerSlice("b", map[string]bool{"pod-c": false}),
+	})
+
+	ring := NewRing(names, 0)
+	members := ring.Members()
+	if len(members) != 2 || members[0] != "pod-a" || members[1] != "pod-c" {
+		t.Errorf("Expected members [pod-a pod-c], got %v", members)
+	}
+}
+
+func TestCoordinatorMembership(t *testing.T) {
+	client := fake.NewClientset(
+		newPeerSlice("peers-1", map[string]bool{"pod-a": false, "pod-b": false}),
+		// Slices of other services are ignored
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other",
+				Namespace: "monitoring",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "other"},
+			},
+			Endpoints: []discoveryv1.Endpoint{{TargetRef: &corev1.ObjectReference{Name: "other-pod"}}},
+		},
+	)
+
+	changed := make(chan struct{}, 10)
+	c := NewCoordinator(client, "monitoring", "k8s-http-monitor-peers", "pod-a", func() {
+		changed <- struct{}{}
+	})
+
+	// Before syncing, the replica owns nothing
+	if c.Owns("any-key") {
+		t.Errorf("Expected a replica to own no key before its peers are known")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Coordinator did not report a membership change")
+	}
+
+	if !c.WaitForSync(ctx) {
+		t.Fatal("Coordinator did not sync")
+	}
+	members := c.Members()
+	if len(members) != 2 || members[0] != "pod-a" || members[1] != "pod-b" {
+		t.Errorf("Expected members [pod-a pod-b], got %v", members)
+	}
+
+	// Once synced, the keys are split between the members
+	owned := 0
+	for i := 0; i < 100; i++ {
+		if c.Owns(fmt.Sprintf("key-%d", i)) {
+			owned++
+		}
+	}
+	if owned == 0 || owned == 100 {
+		t.Errorf("Expected pod-a to own some of the keys, got %d of 100", owned)
+	}
+}
+
+func TestCoordinatorWithoutPeers(t *testing.T) {
+	c := NewCoordinator(fake.NewClientset(), "monitoring", "k8s-http-monitor-peers", "pod-a", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	if !c.WaitForSync(ctx) {
+		t.Fatal("Coordinator did not sync")
+	}
+
+	// A lone replica owns every key once it knows it has no peers
+	if !c.Owns("any-key") {
+		t.Errorf("Expected a lone replica to own every key")
+	}
+}
@@ -0,0 +1,80 @@
+package sharding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// DefaultVirtualNodes is the number of points each member gets on the ring
+const DefaultVirtualNodes = 128
+
+// Ring is an immutable consistent hash ring mapping keys to members
+type Ring struct {
+	members []string
+	hashes  []uint64
+	owners  map[uint64]string
+}
+
+// NewRing builds a consistent hash ring with the given members and virtual nodes per member
+func NewRing(members []string, virtualNodes int) *Ring {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	r := &Ring{
+		owners: make(map[uint64]string, len(members)*virtualNodes),
+	}
+
+	// Deduplicate and sort members so every replica builds the same ring
+	seen := make(map[string]bool, len(members))
+	for _, member := range members {
+		if member == "" || seen[member] {
+			continue
+		}
+		seen[member] = true
+		r.members = append(r.members, member)
+	}
+	sort.Strings(r.members)
+
+	for _, member := range r.members {
+		for i := 0; i < virtualNodes; i++ {
+			h := hashKey(member + "#" + strconv.Itoa(i))
+			// Members are sorted, so on the unlikely collision the smallest one wins
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = member
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+
+	return r
+}
+
+// Owner returns the member responsible for the key, or "" for an empty ring
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Members returns the sorted list of ring members
+func (r *Ring) Members() []string {
+	return append([]string(nil), r.members...)
+}
+
+// hashKey maps a key onto the ring; SHA-256 spreads similar keys evenly
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
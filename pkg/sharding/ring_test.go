@@ -0,0 +1,61 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingOwnerDeterministic(t *testing.T) {
+	a := NewRing([]string{"pod-a", "pod-b", "pod-c"}, 0)
+	b := NewRing([]string{"pod-c", "pod-a", "pod-b", "pod-a"}, 0)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("default/ingress/http://host-%d.example.com/", i)
+		if a.Owner(key) != b.Owner(key) {
+			t.Fatalf("Rings with the same members disagree on the owner of %s", key)
+		}
+	}
+
+	if members := b.Members(); len(members) != 3 || members[0] != "pod-a" {
+		t.Errorf("Expected deduplicated sorted members, got %v", members)
+	}
+}
+
+func TestRingEmpty(t *testing.T) {
+	if owner := NewRing(nil, 0).Owner("key"); owner != "" {
+		t.Errorf("Expected no owner on an empty ring, got %q", owner)
+	}
+}
+
+func TestRingDistribution(t *testing.T) {
+	members := []string{"pod-a", "pod-b", "pod-c", "pod-d"}
+	ring := NewRing(members, 0)
+
+	counts := make(map[string]int)
+	total := 4000
+	for i := 0; i < total; i++ {
+		counts[ring.Owner(fmt.Sprintf("ns/ingress/http://host-%d.example.com/health", i))]++
+	}
+
+	// Each member should get a reasonable share of the keys
+	for _, member := range members {
+		share := float64(counts[member]) / float64(total)
+		if share < 0.15 || share > 0.35 {
+			t.Errorf("Member %s owns %.0f%% of keys, expected roughly 25%%", member, share*100)
+		}
+	}
+}
+
+func TestRingMinimalMovement(t *testing.T) {
+	before := NewRing([]string{"pod-a", "pod-b", "pod-c"}, 0)
+	after := NewRing([]string{"pod-a", "pod-b", "pod-c", "pod-d"}, 0)
+
+	total := 3000
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("ns/ingress/http://host-%d.example.com/", i)
+		// Keys only ever move to the new member
+		if owner := after.Owner(key); owner != before.Owner(key) && owner != "pod-d" {
+			t.Fatalf("Key %s moved from %s to %s when pod-d joined", key, before.Owner(key), owner)
+		}
+	}
+}